@@ -7,12 +7,14 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,6 +23,7 @@ import (
 
 	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/adapters"
 	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/aggregation"
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/cloudformation"
 	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/pricing"
 	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/terraform"
 	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
@@ -34,7 +37,7 @@ const (
 // Server represents the cost estimation HTTP server
 type Server struct {
 	pool       *pgxpool.Pool
-	loader     *terraform.Loader
+	metadata   *terraform.Metadata // Shared, read-only data source metadata
 	ec2Adapter *adapters.EC2Adapter
 	matcher    *pricing.Matcher
 	registry   *pricing.MatcherRegistry
@@ -71,13 +74,45 @@ func main() {
 	// Create server
 	server := &Server{
 		pool:       pool,
-		loader:     terraform.NewLoader(),
+		metadata:   terraform.DefaultMetadata(),
 		ec2Adapter: adapters.NewEC2Adapter(),
 		matcher:    pricing.NewMatcher(pool),
 		registry:   pricing.NewMatcherRegistry(pool),
 		aggregator: aggregation.NewAggregator(),
 	}
 
+	// Resolve data sources from a local AMI and account metadata table
+	if path := os.Getenv("AWS_METADATA_FILE"); path != "" {
+		meta, err := terraform.LoadMetadata(path)
+		if err != nil {
+			log.Fatalf("Failed to load AWS metadata: %v", err)
+		}
+		server.metadata = meta
+		log.Printf("Loaded AWS metadata from %s (%d images)", path, len(meta.Images))
+	}
+
+	// Price Spot usage at a percentile of the imported spot price history
+	if value := os.Getenv("SPOT_PRICE_PERCENTILE"); value != "" {
+		percentile, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			err = server.matcher.UseSpotPercentile(percentile)
+		}
+		if err != nil {
+			log.Fatalf("Invalid SPOT_PRICE_PERCENTILE %q: %v", value, err)
+		}
+	}
+
+	// Bound the in-memory price lookup cache
+	if value := os.Getenv("PRICE_CACHE_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err == nil {
+			err = server.matcher.UseCacheSize(size)
+		}
+		if err != nil {
+			log.Fatalf("Invalid PRICE_CACHE_SIZE %q: %v", value, err)
+		}
+	}
+
 	// Setup router
 	server.setupRouter()
 
@@ -103,6 +138,11 @@ func (s *Server) setupRouter() {
 	{
 		api.POST("/estimate", s.estimateHandler)
 		api.POST("/estimate/terraform", s.estimateTerraformHandler)
+		api.POST("/estimate/plan", s.estimatePlanHandler)
+		api.POST("/estimate/state", s.estimateStateHandler)
+		api.POST("/estimate/inputs", s.estimateInputsHandler)
+		api.POST("/diff", s.diffHandler)
+		api.POST("/diff/terraform", s.diffTerraformHandler)
 		
 		// Debug endpoints
 		api.GET("/debug/services", s.debugServicesHandler)
@@ -192,16 +232,82 @@ func (s *Server) healthHandler(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "healthy",
-		"version": "1.0.0",
+		"status":      "healthy",
+		"version":     "1.0.0",
+		"price_cache": s.matcher.CacheStats(),
 	})
 }
 
+// EstimateInput is a configuration to estimate, given as exactly one of
+// a ZIP, inline HCL, plan JSON or a CloudFormation template
+type EstimateInput struct {
+	TerraformZip   []byte                 `json:"terraform_zip,omitempty"`  // Base64 encoded ZIP
+	TerraformHCL   string                 `json:"terraform_hcl,omitempty"`  // Raw HCL content
+	TerraformPlan  json.RawMessage        `json:"terraform_plan,omitempty"` // `terraform show -json` plan output
+	CloudFormation string                 `json:"cloudformation,omitempty"` // CloudFormation or SAM template, YAML or JSON; variables set its parameters
+	Variables      map[string]interface{} `json:"variables,omitempty"`      // Explicit variable values
+	VarFiles       []string               `json:"var_files,omitempty"`      // Var files inside the ZIP, in order
+}
+
+// PurchaseOptions selects the Reserved or Savings Plans commitments
+// instance usage is priced under. PurchaseOption applies to every resource
+// without an entry in PurchaseOptions, which is keyed by resource address.
+type PurchaseOptions struct {
+	PurchaseOption  *types.PurchaseOption           `json:"purchase_option,omitempty"`
+	PurchaseOptions map[string]types.PurchaseOption `json:"purchase_options,omitempty"`
+}
+
+// validate fills in purchase option defaults and reports unsupported values
+func (p *PurchaseOptions) validate() error {
+	if p.PurchaseOption != nil {
+		if err := pricing.NormalizePurchaseOption(p.PurchaseOption); err != nil {
+			return fmt.Errorf("purchase_option: %w", err)
+		}
+	}
+	for address, opt := range p.PurchaseOptions {
+		if err := pricing.NormalizePurchaseOption(&opt); err != nil {
+			return fmt.Errorf("purchase_options[%q]: %w", address, err)
+		}
+		p.PurchaseOptions[address] = opt
+	}
+	return nil
+}
+
+// forResource returns the purchase option of a resource instance. An entry
+// for the resource address without its instance key covers every instance.
+func (p PurchaseOptions) forResource(address string) *types.PurchaseOption {
+	if opt, ok := p.PurchaseOptions[address]; ok {
+		return &opt
+	}
+	if idx := strings.LastIndex(address, "["); idx > 0 && strings.HasSuffix(address, "]") {
+		if opt, ok := p.PurchaseOptions[address[:idx]]; ok {
+			return &opt
+		}
+	}
+	return p.PurchaseOption
+}
+
 // EstimateRequest represents the request body for cost estimation
 type EstimateRequest struct {
-	Region    string `json:"region" binding:"required"`
-	TerraformZip []byte `json:"terraform_zip,omitempty"` // Base64 encoded ZIP
-	TerraformHCL string `json:"terraform_hcl,omitempty"` // Raw HCL content
+	Region string `json:"region" binding:"required"`
+	EstimateInput
+	PurchaseOptions
+	TerraformState json.RawMessage `json:"terraform_state,omitempty"` // terraform.tfstate of the deployed footprint
+	Mode           string          `json:"mode,omitempty"`            // "inputs" returns required inputs instead of an estimate
+}
+
+// modeInputs asks for the variables an estimate is missing instead of the estimate
+const modeInputs = "inputs"
+
+// InputsResponse lists the variables that must be supplied for a complete estimate
+type InputsResponse struct {
+	RequiredInputs []types.RequiredInput `json:"required_inputs"`
+	InputHash      string                `json:"input_hash"`
+}
+
+// empty reports whether no configuration was supplied
+func (in EstimateInput) empty() bool {
+	return in.TerraformHCL == "" && len(in.TerraformZip) == 0 && len(in.TerraformPlan) == 0 && in.CloudFormation == ""
 }
 
 // estimateHandler handles POST /api/v1/estimate
@@ -211,9 +317,8 @@ func (s *Server) estimateHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	if req.TerraformHCL == "" && len(req.TerraformZip) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "terraform_hcl or terraform_zip required"})
+	if err := req.PurchaseOptions.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -222,33 +327,73 @@ func (s *Server) estimateHandler(c *gin.Context) {
 		region = defaultRegion
 	}
 
-	var plan *types.TerraformPlan
-	var inputHash string
-	var err error
+	hasConfig := !req.EstimateInput.empty()
+	if req.Mode == modeInputs {
+		if !hasConfig {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "terraform_hcl, terraform_zip, terraform_plan or cloudformation required"})
+			return
+		}
+		plan, inputHash, err := s.processInput(req.EstimateInput, region)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, s.requiredInputs(plan, inputHash))
+		return
+	}
+	if !hasConfig && len(req.TerraformState) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "terraform_hcl, terraform_zip, terraform_plan, cloudformation or terraform_state required"})
+		return
+	}
 
-	if len(req.TerraformZip) > 0 {
-		// Process ZIP file
-		plan, inputHash, err = s.processZip(req.TerraformZip)
-	} else {
-		// Process inline HCL
-		plan, inputHash, err = s.processHCL(req.TerraformHCL)
+	// Price the deployed footprint when a state file is supplied
+	var current *types.CostEstimate
+	if len(req.TerraformState) > 0 {
+		estimate, status, err := s.estimateState(c.Request.Context(), req.TerraformState, region, req.PurchaseOptions)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if !hasConfig {
+			c.JSON(http.StatusOK, estimate)
+			return
+		}
+		current = estimate
 	}
 
+	plan, inputHash, err := s.processInput(req.EstimateInput, region)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Generate cost estimate
-	estimate, err := s.generateEstimate(c.Request.Context(), plan, region, inputHash)
+	estimate, err := s.generateEstimate(c.Request.Context(), plan, region, inputHash, req.PurchaseOptions)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	estimate.Current = current
 
 	c.JSON(http.StatusOK, estimate)
 }
 
+// loadOptionsForm parses the var_file and variables fields of a multipart
+// upload, responding with an error on failure
+func loadOptionsForm(c *gin.Context) (terraform.LoadOptions, bool) {
+	// Variables are a JSON object; var files are named once per var_file field
+	opts := terraform.LoadOptions{
+		VarFiles: c.PostFormArray("var_file"),
+	}
+	if raw := c.PostForm("variables"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts.Variables); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "variables must be a JSON object: " + err.Error()})
+			return opts, false
+		}
+	}
+	return opts, true
+}
+
 // estimateTerraformHandler handles multipart form upload
 func (s *Server) estimateTerraformHandler(c *gin.Context) {
 	// Get region from form
@@ -257,44 +402,286 @@ func (s *Server) estimateTerraformHandler(c *gin.Context) {
 		region = defaultRegion
 	}
 
+	plan, inputHash, ok := s.processTerraformForm(c, region)
+	if !ok {
+		return
+	}
+
+	if c.PostForm("mode") == modeInputs {
+		c.JSON(http.StatusOK, s.requiredInputs(plan, inputHash))
+		return
+	}
+
+	purchase, ok := purchaseOptionsForm(c)
+	if !ok {
+		return
+	}
+
+	// Generate cost estimate
+	estimate, err := s.generateEstimate(c.Request.Context(), plan, region, inputHash, purchase)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Optionally price the deployed footprint alongside the configuration
+	if _, err := c.FormFile("state"); err == nil {
+		stateData, err := readFormFile(c, "state")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		current, status, err := s.estimateState(c.Request.Context(), stateData, region, purchase)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		estimate.Current = current
+	}
+
+	c.JSON(http.StatusOK, estimate)
+}
+
+// processTerraformForm parses the terraform file, var_file and variables
+// fields of a multipart upload for an estimate in region, responding with an
+// error on failure
+func (s *Server) processTerraformForm(c *gin.Context, region string) (*types.TerraformPlan, string, bool) {
 	// Get uploaded file
 	file, err := c.FormFile("terraform")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "terraform file required"})
-		return
+		return nil, "", false
 	}
 
 	// Read file content
 	f, err := file.Open()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return nil, "", false
 	}
 	defer f.Close()
 
 	content, err := io.ReadAll(f)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, "", false
+	}
+
+	opts, ok := loadOptionsForm(c)
+	if !ok {
+		return nil, "", false
+	}
+
+	plan, inputHash, err := s.processUpload(file.Filename, content, region, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, "", false
+	}
+
+	return plan, inputHash, true
+}
+
+// purchaseOptionsForm parses the purchase_option and purchase_options form
+// fields, each a JSON object, responding with an error on failure
+func purchaseOptionsForm(c *gin.Context) (PurchaseOptions, bool) {
+	var purchase PurchaseOptions
+	if raw := c.PostForm("purchase_option"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &purchase.PurchaseOption); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "purchase_option must be a JSON object: " + err.Error()})
+			return purchase, false
+		}
+	}
+	if raw := c.PostForm("purchase_options"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &purchase.PurchaseOptions); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "purchase_options must be a JSON object: " + err.Error()})
+			return purchase, false
+		}
+	}
+	if err := purchase.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return purchase, false
+	}
+	return purchase, true
+}
+
+// estimateInputsHandler handles POST /api/v1/estimate/inputs: it returns the
+// variables without a value that priced attributes depend on
+func (s *Server) estimateInputsHandler(c *gin.Context) {
+	region := c.PostForm("region")
+	if region == "" {
+		region = defaultRegion
+	}
+
+	plan, inputHash, ok := s.processTerraformForm(c, region)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, s.requiredInputs(plan, inputHash))
+}
+
+// requiredInputs lists the plan's required inputs that affect resources the
+// engine prices; inputs used only by unpriced resources are left out
+func (s *Server) requiredInputs(plan *types.TerraformPlan, inputHash string) InputsResponse {
+	resp := InputsResponse{
+		RequiredInputs: []types.RequiredInput{},
+		InputHash:      inputHash,
+	}
+	for _, input := range plan.RequiredInputs {
+		var usedBy []types.InputUsage
+		for _, usage := range input.UsedBy {
+			if s.registry.FindMatcher(usage.ResourceType) != nil || s.ec2Adapter.CanHandle(usage.ResourceType) {
+				usedBy = append(usedBy, usage)
+			}
+		}
+		if len(usedBy) == 0 {
+			continue
+		}
+		input.UsedBy = usedBy
+		resp.RequiredInputs = append(resp.RequiredInputs, input)
+	}
+	return resp
+}
+
+// DiffRequest represents the request body for a cost diff
+type DiffRequest struct {
+	Region   string        `json:"region" binding:"required"`
+	Baseline EstimateInput `json:"baseline"`
+	Proposed EstimateInput `json:"proposed"`
+
+	// Purchase options apply to both sides
+	PurchaseOptions
+}
+
+// diffHandler handles POST /api/v1/diff
+func (s *Server) diffHandler(c *gin.Context) {
+	var req DiffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.PurchaseOptions.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Baseline.empty() || req.Proposed.empty() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "baseline and proposed configurations required"})
+		return
+	}
+
+	region := req.Region
+	if region == "" {
+		region = defaultRegion
+	}
+
+	var inputs [2]diffInput
+	for i, in := range []EstimateInput{req.Baseline, req.Proposed} {
+		plan, inputHash, err := s.processInput(in, region)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": diffSides[i] + ": " + err.Error()})
+			return
+		}
+		inputs[i] = diffInput{plan: plan, inputHash: inputHash}
+	}
+
+	s.respondDiff(c, inputs, region, req.PurchaseOptions)
+}
+
+// diffTerraformHandler handles multipart upload of baseline and proposed files
+func (s *Server) diffTerraformHandler(c *gin.Context) {
+	// Get region from form
+	region := c.PostForm("region")
+	if region == "" {
+		region = defaultRegion
+	}
+
+	purchase, ok := purchaseOptionsForm(c)
+	if !ok {
 		return
 	}
 
-	var plan *types.TerraformPlan
-	var inputHash string
+	// Both sides are loaded with the same variables
+	opts, ok := loadOptionsForm(c)
+	if !ok {
+		return
+	}
 
-	// Check if ZIP or HCL
-	if strings.HasSuffix(file.Filename, ".zip") {
-		plan, inputHash, err = s.processZip(content)
-	} else {
-		plan, inputHash, err = s.processHCL(string(content))
+	var inputs [2]diffInput
+	for i, field := range diffSides {
+		file, err := c.FormFile(field)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": field + " file required"})
+			return
+		}
+		content, err := readFormFile(c, field)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		plan, inputHash, err := s.processUpload(file.Filename, content, region, opts)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": field + ": " + err.Error()})
+			return
+		}
+		inputs[i] = diffInput{plan: plan, inputHash: inputHash}
+	}
+
+	s.respondDiff(c, inputs, region, purchase)
+}
+
+// diffSides names the two sides of a diff in request order
+var diffSides = []string{"baseline", "proposed"}
+
+// diffInput is one parsed side of a diff
+type diffInput struct {
+	plan      *types.TerraformPlan
+	inputHash string
+}
+
+// respondDiff estimates both sides and writes their cost diff
+func (s *Server) respondDiff(c *gin.Context, inputs [2]diffInput, region string, purchase PurchaseOptions) {
+	var estimates [2]*types.CostEstimate
+	for i, in := range inputs {
+		estimate, err := s.generateEstimate(c.Request.Context(), in.plan, region, in.inputHash, purchase)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		estimates[i] = estimate
+	}
+
+	c.JSON(http.StatusOK, s.aggregator.Diff(estimates[0], estimates[1]))
+}
+
+// estimatePlanHandler handles multipart upload of `terraform show -json` output
+func (s *Server) estimatePlanHandler(c *gin.Context) {
+	// Get region from form
+	region := c.PostForm("region")
+	if region == "" {
+		region = defaultRegion
 	}
 
+	// Get uploaded plan
+	content, err := readFormFile(c, "plan")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	plan, inputHash, err := s.processPlanJSON(content)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchase, ok := purchaseOptionsForm(c)
+	if !ok {
+		return
+	}
+
 	// Generate cost estimate
-	estimate, err := s.generateEstimate(c.Request.Context(), plan, region, inputHash)
+	estimate, err := s.generateEstimate(c.Request.Context(), plan, region, inputHash, purchase)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -303,11 +690,143 @@ func (s *Server) estimateTerraformHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, estimate)
 }
 
+// estimateStateHandler handles multipart upload of a terraform.tfstate file
+func (s *Server) estimateStateHandler(c *gin.Context) {
+	// Get region from form
+	region := c.PostForm("region")
+	if region == "" {
+		region = defaultRegion
+	}
+
+	content, err := readFormFile(c, "state")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchase, ok := purchaseOptionsForm(c)
+	if !ok {
+		return
+	}
+
+	estimate, status, err := s.estimateState(c.Request.Context(), content, region, purchase)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, estimate)
+}
+
+// estimateState prices the resources recorded in a state file, returning
+// the HTTP status to report on failure
+func (s *Server) estimateState(ctx context.Context, stateData []byte, region string, purchase PurchaseOptions) (*types.CostEstimate, int, error) {
+	hash := sha256.Sum256(stateData)
+	inputHash := "sha256:" + hex.EncodeToString(hash[:])
+
+	plan, err := terraform.ParseState(stateData)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("failed to parse state: %w", err)
+	}
+
+	estimate, err := s.generateEstimate(ctx, plan, region, inputHash, purchase)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return estimate, http.StatusOK, nil
+}
+
+// readFormFile reads the content of an uploaded multipart file
+func readFormFile(c *gin.Context, field string) ([]byte, error) {
+	file, err := c.FormFile(field)
+	if err != nil {
+		return nil, fmt.Errorf("%s file required", field)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// processInput parses whichever configuration an EstimateInput carries for
+// an estimate in region
+func (s *Server) processInput(in EstimateInput, region string) (*types.TerraformPlan, string, error) {
+	opts := terraform.LoadOptions{
+		VarFiles:  in.VarFiles,
+		Variables: in.Variables,
+	}
+
+	switch {
+	case len(in.TerraformPlan) > 0:
+		// Process plan JSON (values already resolved by Terraform)
+		return s.processPlanJSON(in.TerraformPlan)
+	case in.CloudFormation != "":
+		return s.processCloudFormation([]byte(in.CloudFormation), "template.yaml", region, opts)
+	case len(in.TerraformZip) > 0:
+		// Process ZIP file
+		return s.processZip(in.TerraformZip, opts)
+	default:
+		// Process inline HCL
+		return s.processHCL(in.TerraformHCL, opts)
+	}
+}
+
+// processUpload parses an uploaded file as a ZIP, plan JSON, CloudFormation
+// template or HCL based on its name; region is the estimate region templates
+// see as AWS::Region
+func (s *Server) processUpload(filename string, content []byte, region string, opts terraform.LoadOptions) (*types.TerraformPlan, string, error) {
+	switch {
+	case strings.HasSuffix(filename, ".zip"):
+		return s.processZip(content, opts)
+	case strings.HasSuffix(filename, ".tf.json"):
+		return s.processHCL(string(content), opts)
+	case strings.HasSuffix(filename, ".yaml"), strings.HasSuffix(filename, ".yml"), strings.HasSuffix(filename, ".template"):
+		return s.processCloudFormation(content, filename, region, opts)
+	case strings.HasSuffix(filename, ".json"):
+		if cloudformation.IsTemplate(content) {
+			return s.processCloudFormation(content, filename, region, opts)
+		}
+		return s.processPlanJSON(content)
+	default:
+		return s.processHCL(string(content), opts)
+	}
+}
+
+// processPlanJSON parses `terraform show -json` plan output
+func (s *Server) processPlanJSON(planData []byte) (*types.TerraformPlan, string, error) {
+	hash := sha256.Sum256(planData)
+	inputHash := "sha256:" + hex.EncodeToString(hash[:])
+
+	plan, err := terraform.ParsePlanJSON(planData)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse plan: %w", err)
+	}
+
+	return plan, inputHash, nil
+}
+
+// processCloudFormation parses a CloudFormation or SAM template, binding its
+// parameters from the request variables and AWS::Region to region
+func (s *Server) processCloudFormation(content []byte, filename, region string, opts terraform.LoadOptions) (*types.TerraformPlan, string, error) {
+	inputHash := hashInput(content, opts)
+
+	plan, err := cloudformation.Parse(content, filepath.Base(filename), region, opts.Variables)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return plan, inputHash, nil
+}
+
 // processZip extracts and parses Terraform files from a ZIP archive
-func (s *Server) processZip(zipData []byte) (*types.TerraformPlan, string, error) {
+func (s *Server) processZip(zipData []byte, opts terraform.LoadOptions) (*types.TerraformPlan, string, error) {
 	// Calculate input hash
-	hash := sha256.Sum256(zipData)
-	inputHash := "sha256:" + hex.EncodeToString(hash[:])
+	inputHash := hashInput(zipData, opts)
 
 	// Create temp directory
 	tempDir, err := os.MkdirTemp("", "terraform-*")
@@ -356,7 +875,7 @@ func (s *Server) processZip(zipData []byte) (*types.TerraformPlan, string, error
 	}
 
 	// Parse Terraform files
-	plan, err := s.loader.LoadDirectory(tempDir)
+	plan, err := s.newLoader().LoadDirectory(tempDir, opts)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to parse Terraform: %w", err)
 	}
@@ -364,27 +883,38 @@ func (s *Server) processZip(zipData []byte) (*types.TerraformPlan, string, error
 	return plan, inputHash, nil
 }
 
-// processHCL parses inline HCL content
-func (s *Server) processHCL(hcl string) (*types.TerraformPlan, string, error) {
+// newLoader returns a Loader for a single request. Loaders hold the state
+// of one evaluation, so concurrent requests must not share one.
+func (s *Server) newLoader() *terraform.Loader {
+	loader := terraform.NewLoader()
+	loader.UseMetadata(s.metadata)
+	return loader
+}
+
+// processHCL parses inline HCL content, in native or JSON syntax
+func (s *Server) processHCL(hcl string, opts terraform.LoadOptions) (*types.TerraformPlan, string, error) {
 	// Calculate input hash
-	hash := sha256.Sum256([]byte(hcl))
-	inputHash := "sha256:" + hex.EncodeToString(hash[:])
+	inputHash := hashInput([]byte(hcl), opts)
 
-	// Create temp file
-	tempFile, err := os.CreateTemp("", "terraform-*.tf")
+	// Write to a private temp directory so only this file is loaded
+	tempDir, err := os.MkdirTemp("", "terraform-*")
 	if err != nil {
-		return nil, "", err
+		return nil, "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// JSON syntax is a single object; native syntax never starts with "{"
+	filename := "main.tf"
+	if strings.HasPrefix(strings.TrimSpace(hcl), "{") {
+		filename = "main.tf.json"
 	}
-	defer os.Remove(tempFile.Name())
 
-	if _, err := tempFile.WriteString(hcl); err != nil {
-		tempFile.Close()
+	if err := os.WriteFile(filepath.Join(tempDir, filename), []byte(hcl), 0644); err != nil {
 		return nil, "", err
 	}
-	tempFile.Close()
 
 	// Parse
-	plan, err := s.loader.LoadDirectory(filepath.Dir(tempFile.Name()))
+	plan, err := s.newLoader().LoadDirectory(tempDir, opts)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to parse Terraform: %w", err)
 	}
@@ -392,8 +922,21 @@ func (s *Server) processHCL(hcl string) (*types.TerraformPlan, string, error) {
 	return plan, inputHash, nil
 }
 
+// hashInput returns the reproducibility hash of an input, covering
+// variable overrides when any are given
+func hashInput(content []byte, opts terraform.LoadOptions) string {
+	h := sha256.New()
+	h.Write(content)
+	if len(opts.VarFiles) > 0 || len(opts.Variables) > 0 {
+		// encoding/json sorts map keys, so this is deterministic
+		overrides, _ := json.Marshal(opts)
+		h.Write(overrides)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
 // generateEstimate creates a cost estimate from a parsed Terraform plan
-func (s *Server) generateEstimate(ctx context.Context, plan *types.TerraformPlan, region string, inputHash string) (*types.CostEstimate, error) {
+func (s *Server) generateEstimate(ctx context.Context, plan *types.TerraformPlan, region string, inputHash string, purchase PurchaseOptions) (*types.CostEstimate, error) {
 	var allVectors []types.UsageVector
 
 	// Log parsing results
@@ -404,13 +947,25 @@ func (s *Server) generateEstimate(ctx context.Context, plan *types.TerraformPlan
 
 	// Convert resources to usage vectors using registry
 	for _, resource := range plan.Resources {
+		// Price in the resource's provider region, falling back to the request region
+		resourceRegion := resource.Region
+		if resourceRegion == "" {
+			resourceRegion = region
+		}
+
 		// First try the new matcher registry
 		if matcher := s.registry.FindMatcher(resource.Type); matcher != nil {
-			vectors, err := matcher.Match(ctx, resource, region)
+			vectors, err := matcher.Match(ctx, resource, resourceRegion)
 			if err != nil {
 				log.Printf("Warning: matcher error for %s: %v", resource.Type, err)
 			} else {
 				log.Printf("Matched %s with %d usage vectors", resource.Address, len(vectors))
+				// Attribute vectors to the full resource address (including module path)
+				for i := range vectors {
+					if vectors[i].ResourceAddress == "" {
+						vectors[i].ResourceAddress = resource.Address
+					}
+				}
 				allVectors = append(allVectors, vectors...)
 				continue
 			}
@@ -418,24 +973,23 @@ func (s *Server) generateEstimate(ctx context.Context, plan *types.TerraformPlan
 
 		// Fallback to legacy EC2 adapter
 		if s.ec2Adapter.CanHandle(resource.Type) {
-			vectors := s.ec2Adapter.Adapt(resource, region)
+			vectors := s.ec2Adapter.Adapt(resource, resourceRegion)
 			log.Printf("Legacy adapter matched %s with %d usage vectors", resource.Address, len(vectors))
 			allVectors = append(allVectors, vectors...)
 		}
 	}
 
-	// Match vectors to prices
-	var pricedItems []types.PricedItem
-	for _, vector := range allVectors {
-		priced, err := s.matcher.Match(ctx, vector)
-		if err != nil {
-			// Log error but continue
-			log.Printf("Warning: failed to match %s: %v", vector.UsageType, err)
-			continue
-		}
-		if priced != nil {
-			pricedItems = append(pricedItems, *priced)
-		}
+	// Match vectors to prices in one batch
+	for i := range allVectors {
+		allVectors[i].PurchaseOption = purchase.forResource(allVectors[i].ResourceAddress)
+	}
+	priced, err := s.matcher.MatchBatch(ctx, allVectors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match prices: %w", err)
+	}
+	pricedItems := make([]types.PricedItem, 0, len(priced))
+	for _, item := range priced {
+		pricedItems = append(pricedItems, *item)
 	}
 
 	// Get catalog version
@@ -451,6 +1005,21 @@ func (s *Server) generateEstimate(ctx context.Context, plan *types.TerraformPlan
 
 	estimate := s.aggregator.Aggregate(pricedItems, metadata)
 
+	// Link each costed resource back to its declaration and Terragrunt unit
+	sources := make(map[string]*types.SourceRange, len(plan.Resources))
+	stacks := make(map[string]string, len(plan.Resources))
+	for _, resource := range plan.Resources {
+		sources[resource.Address] = resource.Source
+		stacks[resource.Address] = resource.Stack
+	}
+	for i := range estimate.ByResource {
+		estimate.ByResource[i].Source = sources[estimate.ByResource[i].Address]
+		estimate.ByResource[i].Stack = stacks[estimate.ByResource[i].Address]
+	}
+	estimate.ByStack = s.aggregator.ByStack(estimate.ByResource)
+	estimate.NotCreated = plan.NotCreated
+	estimate.Diagnostics = plan.Diagnostics
+
 	return &estimate, nil
 }
 
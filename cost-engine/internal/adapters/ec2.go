@@ -34,6 +34,17 @@ func (a *EC2Adapter) Adapt(resource types.TerraformResource, region string) []ty
 	instanceType := a.getStringAttr(resource.Config, "instance_type", "t3.micro")
 	
 	// EC2 Instance compute usage
+	attributes := map[string]string{
+		"instanceType":    instanceType,
+		"tenancy":         a.getStringAttr(resource.Config, "tenancy", "Shared"),
+		"operatingSystem": a.guessOS(resource),
+		"preInstalledSw":  "NA",
+		"capacitystatus":  "Used",
+	}
+	if resource.Platform != nil {
+		attributes["preInstalledSw"] = resource.Platform.PreInstalledSw
+		attributes["licenseModel"] = resource.Platform.LicenseModel
+	}
 	vectors = append(vectors, types.UsageVector{
 		ResourceAddress: resource.Address,
 		Service:         "AmazonEC2",
@@ -42,13 +53,8 @@ func (a *EC2Adapter) Adapt(resource types.TerraformResource, region string) []ty
 		Unit:            "Hrs",
 		Quantity:        a.DefaultHoursPerMonth,
 		Confidence:      types.ConfidenceHigh,
-		Attributes: map[string]string{
-			"instanceType":    instanceType,
-			"tenancy":         a.getStringAttr(resource.Config, "tenancy", "Shared"),
-			"operatingSystem": a.guessOS(resource),
-			"preInstalledSw":  "NA",
-			"capacitystatus":  "Used",
-		},
+		Attributes:      attributes,
+		Market:          a.market(resource),
 	})
 
 	// EBS Root Volume
@@ -138,6 +144,11 @@ func (a *EC2Adapter) Adapt(resource types.TerraformResource, region string) []ty
 
 // guessOS attempts to determine the OS from the AMI or config
 func (a *EC2Adapter) guessOS(resource types.TerraformResource) string {
+	// Prefer the platform of the resolved machine image
+	if resource.Platform != nil {
+		return resource.Platform.OperatingSystem
+	}
+
 	ami := a.getStringAttr(resource.Config, "ami", "")
 	
 	// Simple heuristics based on common AMI patterns
@@ -156,6 +167,15 @@ func (a *EC2Adapter) guessOS(resource types.TerraformResource) string {
 	return "Linux"
 }
 
+// market returns Spot when instance_market_options requests spot capacity
+func (a *EC2Adapter) market(resource types.TerraformResource) types.Market {
+	opts := a.getBlockDevice(resource.Config, "instance_market_options")
+	if opts != nil && strings.EqualFold(a.getStringAttr(opts, "market_type", ""), "spot") {
+		return types.MarketSpot
+	}
+	return ""
+}
+
 // getStringAttr safely extracts a string attribute
 func (a *EC2Adapter) getStringAttr(config map[string]interface{}, key, defaultVal string) string {
 	if val, ok := config[key]; ok {
@@ -2,9 +2,17 @@
 package aggregation
 
 import (
+	"strings"
+
 	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
 )
 
+// savingsPlansWarning explains that Savings Plans are priced from Reserved
+// rates, since the pricing catalog has no Savings Plans rates
+const savingsPlansWarning = "Savings Plans are approximated at Reserved Instance rates " +
+	"(EC2 Instance Savings Plans at Standard, Compute Savings Plans at Convertible); " +
+	"the actual Savings Plans discount may differ"
+
 // Aggregator combines priced items into resource and service-level costs
 type Aggregator struct{}
 
@@ -26,6 +34,9 @@ func (a *Aggregator) Aggregate(items []types.PricedItem, metadata types.Estimate
 
 	// Group items by resource address
 	resourceMap := make(map[string]*types.ResourceCost)
+	// Resources with line items priced under a commitment or on Spot
+	committed := make(map[string]bool)
+	savingsPlans := false
 
 	for _, item := range items {
 		// Get or create resource cost
@@ -46,17 +57,38 @@ func (a *Aggregator) Aggregate(items []types.PricedItem, metadata types.Estimate
 		// Add line item
 		rc.LineItems = append(rc.LineItems, item)
 		rc.MonthlyCost += item.MonthlyCost
+		rc.UpfrontCost += item.UpfrontCost
+		rc.OnDemandMonthlyCost += onDemandCost(item)
 
 		// Collect assumptions
 		rc.Assumptions = append(rc.Assumptions, item.Assumptions...)
 
 		// Update total
 		estimate.TotalMonthlyCost += item.MonthlyCost
+		estimate.TotalUpfrontCost += item.UpfrontCost
+		estimate.OnDemandMonthlyCost += onDemandCost(item)
+		if item.OnDemandMonthlyCost > 0 {
+			committed[item.ResourceAddress] = true
+			if isSavingsPlan(item.PurchaseOption) {
+				savingsPlans = true
+			}
+		}
+	}
+	if savingsPlans {
+		estimate.Warnings = append(estimate.Warnings, savingsPlansWarning)
+	}
+
+	// The on-demand comparison is only reported where a commitment or Spot applies
+	if len(committed) == 0 {
+		estimate.OnDemandMonthlyCost = 0
 	}
 
 	// Calculate resource confidence and convert to slice
 	for _, rc := range resourceMap {
 		rc.Confidence = a.calculateResourceConfidence(rc.LineItems)
+		if !committed[rc.Address] {
+			rc.OnDemandMonthlyCost = 0
+		}
 		estimate.ByResource = append(estimate.ByResource, *rc)
 		estimate.Assumptions = append(estimate.Assumptions, rc.Assumptions...)
 
@@ -85,6 +117,48 @@ func (a *Aggregator) Aggregate(items []types.PricedItem, metadata types.Estimate
 	return estimate
 }
 
+// isSavingsPlan reports whether a purchase option is a Savings Plan
+func isSavingsPlan(opt *types.PurchaseOption) bool {
+	return opt != nil && (opt.Term == types.PurchaseComputeSavingsPlan || opt.Term == types.PurchaseInstanceSavingsPlan)
+}
+
+// onDemandCost returns what a line item costs on demand
+func onDemandCost(item types.PricedItem) float64 {
+	if item.OnDemandMonthlyCost > 0 {
+		return item.OnDemandMonthlyCost
+	}
+	return item.MonthlyCost
+}
+
+// ByStack totals resource costs per Terragrunt unit. It returns nil when no
+// resource belongs to a unit.
+func (a *Aggregator) ByStack(resources []types.ResourceCost) map[string]types.StackCost {
+	var byStack map[string]types.StackCost
+	for _, rc := range resources {
+		if rc.Stack == "" {
+			continue
+		}
+		if byStack == nil {
+			byStack = make(map[string]types.StackCost)
+		}
+
+		if sc, exists := byStack[rc.Stack]; exists {
+			sc.MonthlyCost += rc.MonthlyCost
+			sc.ResourceCount++
+			sc.Confidence = a.lowerConfidence(sc.Confidence, rc.Confidence)
+			byStack[rc.Stack] = sc
+		} else {
+			byStack[rc.Stack] = types.StackCost{
+				Stack:         rc.Stack,
+				MonthlyCost:   rc.MonthlyCost,
+				ResourceCount: 1,
+				Confidence:    rc.Confidence,
+			}
+		}
+	}
+	return byStack
+}
+
 // calculateResourceConfidence determines confidence for a resource based on its line items
 func (a *Aggregator) calculateResourceConfidence(items []types.PricedItem) types.Confidence {
 	if len(items) == 0 {
@@ -132,6 +206,31 @@ func (a *Aggregator) lowerConfidence(a1, b types.Confidence) types.Confidence {
 
 // parseResourceAddress extracts type and name from a Terraform resource address
 func parseResourceAddress(address string) (resourceType, name string) {
+	// Strip a Terragrunt stack prefix like live/prod/vpc//
+	if idx := strings.Index(address, "//"); idx >= 0 {
+		if bracket := indexOfByte(address, '['); bracket < 0 || idx < bracket {
+			address = address[idx+2:]
+		}
+	}
+
+	// Strip module path segments like module.network[0].
+	for strings.HasPrefix(address, "module.") {
+		rest := address[len("module."):]
+		dot := indexOfByte(rest, '.')
+		if dot < 0 {
+			break
+		}
+		if idx := indexOfByte(rest, '['); idx >= 0 && idx < dot {
+			// Skip past the instance key, which may itself contain dots
+			end := strings.Index(rest[idx:], "].")
+			if end < 0 {
+				break
+			}
+			dot = idx + end + 1
+		}
+		address = rest[dot+1:]
+	}
+
 	// Handle indexed resources like aws_instance.web[0]
 	if idx := indexOfByte(address, '['); idx >= 0 {
 		address = address[:idx]
@@ -0,0 +1,35 @@
+package aggregation
+
+import (
+	"testing"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+func TestAggregateSavingsPlansWarning(t *testing.T) {
+	committed := func(term types.PurchaseTerm) types.PricedItem {
+		item := lineItem("BoxUsage:m5.large", 730, 50)
+		item.ResourceAddress = "aws_instance.web"
+		item.PurchaseOption = &types.PurchaseOption{Term: term}
+		item.OnDemandMonthlyCost = 70
+		return item
+	}
+
+	tests := []struct {
+		name string
+		term types.PurchaseTerm
+		want bool
+	}{
+		{"reserved", types.PurchaseReserved, false},
+		{"compute savings plan", types.PurchaseComputeSavingsPlan, true},
+		{"ec2 instance savings plan", types.PurchaseInstanceSavingsPlan, true},
+	}
+
+	for _, tt := range tests {
+		est := NewAggregator().Aggregate([]types.PricedItem{committed(tt.term)}, types.EstimateMetadata{})
+		got := len(est.Warnings) == 1 && est.Warnings[0] == savingsPlansWarning
+		if got != tt.want {
+			t.Errorf("%s: warnings = %v, want Savings Plans warning %v", tt.name, est.Warnings, tt.want)
+		}
+	}
+}
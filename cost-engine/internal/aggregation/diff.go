@@ -0,0 +1,178 @@
+package aggregation
+
+import (
+	"fmt"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// Diff compares a baseline estimate with a proposed one, reporting
+// resources that were added, removed or changed in cost
+func (a *Aggregator) Diff(baseline, proposed *types.CostEstimate) types.CostDiff {
+	diff := types.CostDiff{
+		Currency:            "USD",
+		BaselineMonthlyCost: baseline.TotalMonthlyCost,
+		ProposedMonthlyCost: proposed.TotalMonthlyCost,
+		MonthlyCostDelta:    proposed.TotalMonthlyCost - baseline.TotalMonthlyCost,
+		Resources:           make(map[string]types.ResourceCostDiff),
+		ByService:           make(map[string]types.ServiceCostDiff),
+		Baseline:            baseline.Metadata,
+		Proposed:            proposed.Metadata,
+	}
+
+	before := make(map[string]types.ResourceCost)
+	for _, rc := range baseline.ByResource {
+		before[rc.Address] = rc
+	}
+	after := make(map[string]types.ResourceCost)
+	for _, rc := range proposed.ByResource {
+		after[rc.Address] = rc
+	}
+
+	for address, old := range before {
+		rd := types.ResourceCostDiff{
+			Address:             address,
+			Type:                old.Type,
+			Name:                old.Name,
+			Service:             old.Service,
+			BaselineMonthlyCost: old.MonthlyCost,
+			Source:              old.Source,
+		}
+
+		cur, exists := after[address]
+		if !exists {
+			rd.Change = types.DiffRemoved
+			rd.MonthlyCostDelta = -old.MonthlyCost
+			rd.LineItems = diffLineItems(old.LineItems, nil)
+			diff.Resources[address] = rd
+			diff.RemovedCount++
+			continue
+		}
+
+		rd.Source = cur.Source
+		rd.ProposedMonthlyCost = cur.MonthlyCost
+		rd.MonthlyCostDelta = cur.MonthlyCost - old.MonthlyCost
+		rd.LineItems = diffLineItems(old.LineItems, cur.LineItems)
+		if len(rd.LineItems) == 0 {
+			continue
+		}
+		rd.Change = types.DiffChanged
+		diff.Resources[address] = rd
+		diff.ChangedCount++
+	}
+
+	for address, cur := range after {
+		if _, exists := before[address]; exists {
+			continue
+		}
+		diff.Resources[address] = types.ResourceCostDiff{
+			Address:             address,
+			Type:                cur.Type,
+			Name:                cur.Name,
+			Service:             cur.Service,
+			Change:              types.DiffAdded,
+			ProposedMonthlyCost: cur.MonthlyCost,
+			MonthlyCostDelta:    cur.MonthlyCost,
+			LineItems:           diffLineItems(nil, cur.LineItems),
+			Source:              cur.Source,
+		}
+		diff.AddedCount++
+	}
+
+	// Service-level deltas, including services present on only one side
+	for service, sc := range baseline.ByService {
+		sd := diff.ByService[service]
+		sd.Service = service
+		sd.BaselineMonthlyCost = sc.MonthlyCost
+		diff.ByService[service] = sd
+	}
+	for service, sc := range proposed.ByService {
+		sd := diff.ByService[service]
+		sd.Service = service
+		sd.ProposedMonthlyCost = sc.MonthlyCost
+		diff.ByService[service] = sd
+	}
+	for service, sd := range diff.ByService {
+		sd.MonthlyCostDelta = sd.ProposedMonthlyCost - sd.BaselineMonthlyCost
+		diff.ByService[service] = sd
+	}
+
+	return diff
+}
+
+// diffLineItems pairs line items by usage type and returns those whose
+// quantity or cost differ. Repeated usage types within a resource (e.g.
+// several gp3 volumes) are paired in order of appearance.
+func diffLineItems(before, after []types.PricedItem) []types.LineItemDiff {
+	var diffs []types.LineItemDiff
+
+	afterByKey := make(map[string]types.PricedItem)
+	var afterKeys []string
+	for _, item := range keyLineItems(after) {
+		afterByKey[item.key] = item.item
+		afterKeys = append(afterKeys, item.key)
+	}
+
+	seen := make(map[string]bool)
+	for _, old := range keyLineItems(before) {
+		seen[old.key] = true
+		d := types.LineItemDiff{
+			Service:             old.item.Service,
+			UsageType:           old.item.UsageType,
+			Unit:                old.item.Unit,
+			BaselineQuantity:    old.item.Quantity,
+			BaselineMonthlyCost: old.item.MonthlyCost,
+			BaselineFormula:     old.item.Formula,
+		}
+		if cur, ok := afterByKey[old.key]; ok {
+			if cur.Quantity == old.item.Quantity && cur.MonthlyCost == old.item.MonthlyCost {
+				continue
+			}
+			d.ProposedQuantity = cur.Quantity
+			d.ProposedMonthlyCost = cur.MonthlyCost
+			d.ProposedFormula = cur.Formula
+		}
+		d.MonthlyCostDelta = d.ProposedMonthlyCost - d.BaselineMonthlyCost
+		diffs = append(diffs, d)
+	}
+
+	for _, key := range afterKeys {
+		if seen[key] {
+			continue
+		}
+		cur := afterByKey[key]
+		diffs = append(diffs, types.LineItemDiff{
+			Service:             cur.Service,
+			UsageType:           cur.UsageType,
+			Unit:                cur.Unit,
+			ProposedQuantity:    cur.Quantity,
+			ProposedMonthlyCost: cur.MonthlyCost,
+			ProposedFormula:     cur.Formula,
+			MonthlyCostDelta:    cur.MonthlyCost,
+		})
+	}
+
+	return diffs
+}
+
+// keyedItem is a line item with its pairing key
+type keyedItem struct {
+	key  string
+	item types.PricedItem
+}
+
+// keyLineItems assigns each line item a key of service, usage type and
+// occurrence index so repeated usage types pair up deterministically
+func keyLineItems(items []types.PricedItem) []keyedItem {
+	occurrences := make(map[string]int)
+	keyed := make([]keyedItem, 0, len(items))
+	for _, item := range items {
+		base := item.Service + "|" + item.UsageType
+		keyed = append(keyed, keyedItem{
+			key:  fmt.Sprintf("%s|%d", base, occurrences[base]),
+			item: item,
+		})
+		occurrences[base]++
+	}
+	return keyed
+}
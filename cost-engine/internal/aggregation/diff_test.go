@@ -0,0 +1,118 @@
+package aggregation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// lineItem returns a priced line item
+func lineItem(usageType string, quantity, cost float64) types.PricedItem {
+	return types.PricedItem{
+		UsageVector: types.UsageVector{Service: "AmazonEC2", UsageType: usageType, Unit: "Hrs", Quantity: quantity},
+		MonthlyCost: cost,
+	}
+}
+
+// estimate returns an estimate of resources, totalled by resource and service
+func estimate(resources ...types.ResourceCost) *types.CostEstimate {
+	est := &types.CostEstimate{ByService: make(map[string]types.ServiceCost)}
+	for _, rc := range resources {
+		for _, item := range rc.LineItems {
+			rc.MonthlyCost += item.MonthlyCost
+		}
+		est.ByResource = append(est.ByResource, rc)
+		est.TotalMonthlyCost += rc.MonthlyCost
+
+		sc := est.ByService[rc.Service]
+		sc.Service = rc.Service
+		sc.MonthlyCost += rc.MonthlyCost
+		est.ByService[rc.Service] = sc
+	}
+	return est
+}
+
+func TestDiff(t *testing.T) {
+	baseline := estimate(
+		types.ResourceCost{Address: "aws_instance.web", Service: "AmazonEC2", LineItems: []types.PricedItem{
+			lineItem("BoxUsage:t3.micro", 730, 7.59),
+			lineItem("EBS:VolumeUsage.gp3", 8, 0.64),
+		}},
+		types.ResourceCost{Address: "aws_instance.old", Service: "AmazonEC2", LineItems: []types.PricedItem{
+			lineItem("BoxUsage:t3.small", 730, 15.18),
+		}},
+		types.ResourceCost{Address: "aws_nat_gateway.this", Service: "AmazonVPC", LineItems: []types.PricedItem{
+			lineItem("NatGateway-Hours", 730, 32.85),
+		}},
+	)
+	proposed := estimate(
+		types.ResourceCost{Address: "aws_instance.web", Service: "AmazonEC2", LineItems: []types.PricedItem{
+			lineItem("BoxUsage:m5.large", 730, 70.08),
+			lineItem("EBS:VolumeUsage.gp3", 8, 0.64),
+		}},
+		types.ResourceCost{Address: "aws_nat_gateway.this", Service: "AmazonVPC", LineItems: []types.PricedItem{
+			lineItem("NatGateway-Hours", 730, 32.85),
+		}},
+		types.ResourceCost{Address: "aws_db_instance.db", Service: "AmazonRDS", LineItems: []types.PricedItem{
+			lineItem("InstanceUsage:db.t3.micro", 730, 12.41),
+		}},
+	)
+
+	diff := NewAggregator().Diff(baseline, proposed)
+
+	if diff.AddedCount != 1 || diff.RemovedCount != 1 || diff.ChangedCount != 1 {
+		t.Errorf("added, removed, changed = %d, %d, %d; want 1, 1, 1", diff.AddedCount, diff.RemovedCount, diff.ChangedCount)
+	}
+	if _, ok := diff.Resources["aws_nat_gateway.this"]; ok {
+		t.Error("unchanged resource reported")
+	}
+
+	wantDelta := proposed.TotalMonthlyCost - baseline.TotalMonthlyCost
+	if math.Abs(diff.MonthlyCostDelta-wantDelta) > 1e-9 {
+		t.Errorf("monthly delta = %g, want %g", diff.MonthlyCostDelta, wantDelta)
+	}
+
+	tests := []struct {
+		address   string
+		change    types.DiffAction
+		delta     float64
+		lineItems int
+	}{
+		{"aws_instance.web", types.DiffChanged, 70.08 - 7.59, 2}, // t3.micro removed, m5.large added; gp3 unchanged
+		{"aws_instance.old", types.DiffRemoved, -15.18, 1},
+		{"aws_db_instance.db", types.DiffAdded, 12.41, 1},
+	}
+	for _, tt := range tests {
+		rd, ok := diff.Resources[tt.address]
+		if !ok {
+			t.Errorf("%s: not in diff", tt.address)
+			continue
+		}
+		if rd.Change != tt.change || math.Abs(rd.MonthlyCostDelta-tt.delta) > 1e-9 || len(rd.LineItems) != tt.lineItems {
+			t.Errorf("%s: %s %g with %d line items; want %s %g with %d", tt.address,
+				rd.Change, rd.MonthlyCostDelta, len(rd.LineItems), tt.change, tt.delta, tt.lineItems)
+		}
+	}
+
+	// Services on only one side still get a delta
+	if sd := diff.ByService["AmazonRDS"]; math.Abs(sd.MonthlyCostDelta-12.41) > 1e-9 || sd.BaselineMonthlyCost != 0 {
+		t.Errorf("AmazonRDS = %+v", sd)
+	}
+	if sd := diff.ByService["AmazonVPC"]; sd.MonthlyCostDelta != 0 {
+		t.Errorf("AmazonVPC = %+v", sd)
+	}
+}
+
+func TestDiffLineItemsPairsRepeatedUsageTypes(t *testing.T) {
+	before := []types.PricedItem{lineItem("EBS:VolumeUsage.gp3", 10, 0.8), lineItem("EBS:VolumeUsage.gp3", 20, 1.6)}
+	after := []types.PricedItem{lineItem("EBS:VolumeUsage.gp3", 10, 0.8), lineItem("EBS:VolumeUsage.gp3", 50, 4)}
+
+	diffs := diffLineItems(before, after)
+	if len(diffs) != 1 {
+		t.Fatalf("got %d line item diffs, want 1: %+v", len(diffs), diffs)
+	}
+	if d := diffs[0]; d.BaselineQuantity != 20 || d.ProposedQuantity != 50 || math.Abs(d.MonthlyCostDelta-2.4) > 1e-9 {
+		t.Errorf("diff = %+v, want the second volume growing from 20 to 50", d)
+	}
+}
@@ -0,0 +1,468 @@
+package cloudformation
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// unknownValue marks a value that cannot be known before deployment, such
+// as a resource attribute or a parameter without a value
+type unknownValue struct{}
+
+// noValueMarker is the result of Ref AWS::NoValue, which removes a property
+type noValueMarker struct{}
+
+var (
+	unknown = unknownValue{}
+	noValue = noValueMarker{}
+)
+
+// evaluator resolves intrinsic functions against a template's parameters,
+// mappings and conditions
+type evaluator struct {
+	tmpl       *template
+	region     string                 // Value of AWS::Region, if known
+	params     map[string]interface{} // Parameters with a value
+	missing    map[string]*types.RequiredInput
+	conditions map[string]bool
+	visiting   map[string]bool
+	used       map[string]bool // Missing parameters referenced by the current property
+	diags      []types.Diagnostic
+}
+
+// newEvaluator binds each parameter to its supplied value or default.
+// SSM parameter types are resolved at deploy time, so their default is a
+// parameter name rather than a value.
+func newEvaluator(tmpl *template, region string, supplied map[string]interface{}) *evaluator {
+	e := &evaluator{
+		tmpl:       tmpl,
+		region:     region,
+		params:     make(map[string]interface{}),
+		missing:    make(map[string]*types.RequiredInput),
+		conditions: make(map[string]bool),
+		visiting:   make(map[string]bool),
+	}
+
+	for name, p := range tmpl.Parameters {
+		val, ok := supplied[name]
+		if !ok && p.Default != nil && !strings.HasPrefix(p.Type, "AWS::SSM::") {
+			val, ok = p.Default, true
+		}
+		if ok {
+			e.params[name] = paramValue(p.Type, val)
+			continue
+		}
+		e.missing[name] = &types.RequiredInput{
+			Name:        name,
+			Type:        p.Type,
+			Description: p.Description,
+			UsedBy:      []types.InputUsage{},
+		}
+	}
+	return e
+}
+
+// resolve evaluates the intrinsic functions in a value
+func (e *evaluator) resolve(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		if len(v) == 1 {
+			for key, arg := range v {
+				if key == "Ref" || strings.HasPrefix(key, "Fn::") {
+					return e.intrinsic(key, arg)
+				}
+			}
+		}
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if r := e.resolve(item); r != noValue {
+				m[key] = r
+			}
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if r := e.resolve(item); r != noValue {
+				list = append(list, r)
+			}
+		}
+		return list
+	default:
+		return val
+	}
+}
+
+// intrinsic evaluates a single intrinsic function
+func (e *evaluator) intrinsic(name string, arg interface{}) interface{} {
+	switch name {
+	case "Ref":
+		ref, ok := e.resolve(arg).(string)
+		if !ok {
+			return unknown
+		}
+		return e.ref(ref)
+
+	case "Fn::If":
+		args, ok := arg.([]interface{})
+		if !ok || len(args) != 3 {
+			return unknown
+		}
+		cond, _ := args[0].(string)
+		create, known := e.condition(cond)
+		if !known {
+			return unknown
+		}
+		if create {
+			return e.resolve(args[1])
+		}
+		return e.resolve(args[2])
+
+	case "Fn::Equals", "Fn::Not", "Fn::And", "Fn::Or":
+		result, known := e.conditionExpr(map[string]interface{}{name: arg})
+		if !known {
+			return unknown
+		}
+		return result
+
+	case "Fn::FindInMap":
+		// Keys are resolved here; the default only when it is used
+		args, ok := arg.([]interface{})
+		if !ok || len(args) < 3 {
+			return unknown
+		}
+		keys := make([]string, 3)
+		for i := range keys {
+			s, ok := scalarString(e.resolve(args[i]))
+			if !ok {
+				return unknown
+			}
+			keys[i] = s
+		}
+		if top, ok := e.tmpl.Mappings[keys[0]].(map[string]interface{}); ok {
+			if second, ok := top[keys[1]].(map[string]interface{}); ok {
+				if val, ok := second[keys[2]]; ok {
+					return e.resolve(val)
+				}
+			}
+		}
+		// The optional fourth argument is { DefaultValue: ... }
+		if len(args) > 3 {
+			if opts, ok := args[3].(map[string]interface{}); ok {
+				if val, ok := opts["DefaultValue"]; ok {
+					return e.resolve(val)
+				}
+			}
+		}
+		return unknown
+
+	case "Fn::Sub":
+		return e.sub(arg)
+
+	case "Fn::Join":
+		args, ok := e.resolve(arg).([]interface{})
+		if !ok || len(args) != 2 {
+			return unknown
+		}
+		delim, ok := args[0].(string)
+		items, isList := args[1].([]interface{})
+		if !ok || !isList {
+			return unknown
+		}
+		parts := make([]string, 0, len(items))
+		for _, item := range items {
+			s, ok := scalarString(item)
+			if !ok {
+				return unknown
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, delim)
+
+	case "Fn::Select":
+		args, ok := e.resolve(arg).([]interface{})
+		if !ok || len(args) != 2 {
+			return unknown
+		}
+		idx, ok := toNumber(args[0]).(float64)
+		items, isList := args[1].([]interface{})
+		if !ok || !isList || int(idx) < 0 || int(idx) >= len(items) {
+			return unknown
+		}
+		return items[int(idx)]
+
+	case "Fn::Split":
+		args, ok := e.resolve(arg).([]interface{})
+		if !ok || len(args) != 2 {
+			return unknown
+		}
+		delim, ok := args[0].(string)
+		s, isString := args[1].(string)
+		if !ok || !isString {
+			return unknown
+		}
+		var items []interface{}
+		for _, part := range strings.Split(s, delim) {
+			items = append(items, part)
+		}
+		return items
+
+	case "Fn::Length":
+		if items, ok := e.resolve(arg).([]interface{}); ok {
+			return float64(len(items))
+		}
+		return unknown
+
+	default:
+		// Fn::GetAtt, Fn::ImportValue, Fn::GetAZs, Fn::Base64 and others
+		// depend on deployed resources or do not affect sizing
+		return unknown
+	}
+}
+
+// ref resolves a Ref to a parameter or pseudo parameter. References to
+// resources are physical IDs, which are unknown until deployment.
+func (e *evaluator) ref(name string) interface{} {
+	if val, ok := e.params[name]; ok {
+		return val
+	}
+	if _, ok := e.missing[name]; ok {
+		if e.used != nil {
+			e.used[name] = true
+		}
+		return unknown
+	}
+
+	switch name {
+	case "AWS::NoValue":
+		return noValue
+	case "AWS::Region":
+		if e.region == "" {
+			return unknown
+		}
+		return e.region
+	case "AWS::Partition":
+		switch {
+		case strings.HasPrefix(e.region, "cn-"):
+			return "aws-cn"
+		case strings.HasPrefix(e.region, "us-gov-"):
+			return "aws-us-gov"
+		}
+		return "aws"
+	case "AWS::URLSuffix":
+		if strings.HasPrefix(e.region, "cn-") {
+			return "amazonaws.com.cn"
+		}
+		return "amazonaws.com"
+	default:
+		// AWS::AccountId, AWS::StackName and resources
+		return unknown
+	}
+}
+
+// subVariable matches ${Name} and ${Resource.Attribute} in Fn::Sub strings;
+// ${!Literal} is an escape
+var subVariable = regexp.MustCompile(`\$\{(!?)([^}]*)\}`)
+
+// sub evaluates Fn::Sub, given as a string or [string, variables]
+func (e *evaluator) sub(arg interface{}) interface{} {
+	var format string
+	vars := make(map[string]interface{})
+	switch v := arg.(type) {
+	case string:
+		format = v
+	case []interface{}:
+		if len(v) != 2 {
+			return unknown
+		}
+		s, ok := v[0].(string)
+		if !ok {
+			return unknown
+		}
+		format = s
+		if m, ok := v[1].(map[string]interface{}); ok {
+			for name, val := range m {
+				vars[name] = e.resolve(val)
+			}
+		}
+	default:
+		return unknown
+	}
+
+	known := true
+	result := subVariable.ReplaceAllStringFunc(format, func(match string) string {
+		parts := subVariable.FindStringSubmatch(match)
+		if parts[1] == "!" {
+			return "${" + parts[2] + "}"
+		}
+
+		name := parts[2]
+		val, ok := vars[name]
+		if !ok {
+			if strings.Contains(name, ".") {
+				// ${Resource.Attribute} is a GetAtt
+				val = unknown
+			} else {
+				val = e.ref(name)
+			}
+		}
+
+		s, ok := scalarString(val)
+		if !ok {
+			known = false
+		}
+		return s
+	})
+
+	if !known {
+		return unknown
+	}
+	return result
+}
+
+// condition evaluates a named condition. known is false if it depends on
+// values that are unknown before deployment.
+func (e *evaluator) condition(name string) (result, known bool) {
+	if result, ok := e.conditions[name]; ok {
+		return result, true
+	}
+	expr, ok := e.tmpl.Conditions[name]
+	if !ok || e.visiting[name] {
+		return false, false
+	}
+
+	e.visiting[name] = true
+	result, known = e.conditionExpr(expr)
+	delete(e.visiting, name)
+
+	if known {
+		e.conditions[name] = result
+	}
+	return result, known
+}
+
+// conditionExpr evaluates a condition function
+func (e *evaluator) conditionExpr(expr interface{}) (result, known bool) {
+	m, ok := expr.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		if b, ok := expr.(bool); ok {
+			return b, true
+		}
+		return false, false
+	}
+
+	for name, arg := range m {
+		args, _ := arg.([]interface{})
+		switch name {
+		case "Condition":
+			cond, _ := arg.(string)
+			return e.condition(cond)
+
+		case "Fn::Equals":
+			if len(args) != 2 {
+				return false, false
+			}
+			a, aok := scalarString(e.resolve(args[0]))
+			b, bok := scalarString(e.resolve(args[1]))
+			if !aok || !bok {
+				return false, false
+			}
+			return a == b, true
+
+		case "Fn::Not":
+			if len(args) != 1 {
+				return false, false
+			}
+			result, known := e.conditionExpr(args[0])
+			return !result, known
+
+		case "Fn::And", "Fn::Or":
+			// A known false (And) or true (Or) decides the result even if
+			// other operands are unknown
+			decisive := name == "Fn::Or"
+			known := true
+			for _, a := range args {
+				result, ok := e.conditionExpr(a)
+				if ok && result == decisive {
+					return decisive, true
+				}
+				known = known && ok
+			}
+			return !decisive, known
+		}
+	}
+	return false, false
+}
+
+// addUsage records that a property depends on a parameter without a value
+func (e *evaluator) addUsage(param string, usage types.InputUsage) {
+	if input, ok := e.missing[param]; ok {
+		input.UsedBy = append(input.UsedBy, usage)
+	}
+}
+
+// requiredInputs returns the parameters without a value, sorted by name
+func (e *evaluator) requiredInputs() []types.RequiredInput {
+	var inputs []types.RequiredInput
+	for _, input := range e.missing {
+		inputs = append(inputs, *input)
+	}
+	sort.Slice(inputs, func(i, j int) bool { return inputs[i].Name < inputs[j].Name })
+	return inputs
+}
+
+// addDiagnostic records a problem found while evaluating the template
+func (e *evaluator) addDiagnostic(severity types.DiagnosticSeverity, summary, detail, address string) {
+	e.diags = append(e.diags, types.Diagnostic{
+		Severity: severity,
+		Summary:  summary,
+		Detail:   detail,
+		Address:  address,
+	})
+}
+
+// scalarString formats a resolved scalar the way CloudFormation compares
+// and substitutes values
+func scalarString(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+// clean removes unknown values so resolved properties have the same shape
+// as loader output, where unknown attributes are omitted
+func clean(val interface{}) interface{} {
+	switch v := val.(type) {
+	case unknownValue, noValueMarker:
+		return nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if c := clean(item); c != nil {
+				m[key] = c
+			}
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if c := clean(item); c != nil {
+				list = append(list, c)
+			}
+		}
+		return list
+	default:
+		return v
+	}
+}
@@ -0,0 +1,292 @@
+package cloudformation
+
+// attrKind is the value type a Terraform attribute expects
+type attrKind int
+
+const (
+	attrString attrKind = iota
+	attrNumber
+	attrBool
+	attrList
+	attrBlock // A nested block, represented as a map
+)
+
+// attrMapping maps a CloudFormation property to a Terraform attribute
+type attrMapping struct {
+	cfn    string
+	tf     string
+	kind   attrKind
+	nested []attrMapping // Properties of a nested block
+}
+
+// resourceMapping maps a CloudFormation resource type to the Terraform
+// resource type whose matcher prices it
+type resourceMapping struct {
+	tfType string
+	attrs  []attrMapping
+	// convert handles properties that do not map one to one
+	convert func(props, config map[string]interface{})
+}
+
+// config converts resolved CloudFormation properties into the attribute
+// names and value types the matchers read
+func (m resourceMapping) config(props map[string]interface{}) map[string]interface{} {
+	config := mapAttrs(props, m.attrs)
+	if m.convert != nil {
+		m.convert(props, config)
+	}
+	return config
+}
+
+// ebsAttrs are the properties of an EBS volume in a block device mapping
+var ebsAttrs = []attrMapping{
+	{cfn: "VolumeSize", tf: "volume_size", kind: attrNumber},
+	{cfn: "VolumeType", tf: "volume_type", kind: attrString},
+	{cfn: "Iops", tf: "iops", kind: attrNumber},
+	{cfn: "Throughput", tf: "throughput", kind: attrNumber},
+	{cfn: "Encrypted", tf: "encrypted", kind: attrBool},
+}
+
+// lambdaAttrs are shared by AWS::Lambda::Function and AWS::Serverless::Function
+var lambdaAttrs = []attrMapping{
+	{cfn: "MemorySize", tf: "memory_size", kind: attrNumber},
+	{cfn: "Timeout", tf: "timeout", kind: attrNumber},
+	{cfn: "Runtime", tf: "runtime", kind: attrString},
+	{cfn: "Architectures", tf: "architectures", kind: attrList},
+	{cfn: "EphemeralStorage", tf: "ephemeral_storage", kind: attrBlock, nested: []attrMapping{
+		{cfn: "Size", tf: "size", kind: attrNumber},
+	}},
+}
+
+// resourceMappings lists the CloudFormation resource types that are priced
+var resourceMappings = map[string]resourceMapping{
+	"AWS::EC2::Instance": {
+		tfType: "aws_instance",
+		attrs: []attrMapping{
+			{cfn: "InstanceType", tf: "instance_type", kind: attrString},
+			{cfn: "ImageId", tf: "ami", kind: attrString},
+			{cfn: "Tenancy", tf: "tenancy", kind: attrString},
+			{cfn: "EbsOptimized", tf: "ebs_optimized", kind: attrBool},
+		},
+		convert: convertBlockDevices,
+	},
+	"AWS::EC2::Volume": {
+		tfType: "aws_ebs_volume",
+		attrs: []attrMapping{
+			{cfn: "Size", tf: "size", kind: attrNumber},
+			{cfn: "VolumeType", tf: "type", kind: attrString},
+			{cfn: "Iops", tf: "iops", kind: attrNumber},
+			{cfn: "Throughput", tf: "throughput", kind: attrNumber},
+		},
+	},
+	"AWS::RDS::DBInstance": {
+		tfType: "aws_db_instance",
+		attrs: []attrMapping{
+			{cfn: "DBInstanceClass", tf: "instance_class", kind: attrString},
+			{cfn: "Engine", tf: "engine", kind: attrString},
+			{cfn: "EngineVersion", tf: "engine_version", kind: attrString},
+			{cfn: "MultiAZ", tf: "multi_az", kind: attrBool},
+			{cfn: "AllocatedStorage", tf: "allocated_storage", kind: attrNumber},
+			{cfn: "StorageType", tf: "storage_type", kind: attrString},
+			{cfn: "Iops", tf: "iops", kind: attrNumber},
+		},
+	},
+	"AWS::RDS::DBCluster": {
+		tfType: "aws_rds_cluster",
+		attrs: []attrMapping{
+			{cfn: "DBClusterInstanceClass", tf: "db_cluster_instance_class", kind: attrString},
+			{cfn: "Engine", tf: "engine", kind: attrString},
+			{cfn: "EngineMode", tf: "engine_mode", kind: attrString},
+			{cfn: "AllocatedStorage", tf: "allocated_storage", kind: attrNumber},
+			{cfn: "StorageType", tf: "storage_type", kind: attrString},
+		},
+	},
+	"AWS::Lambda::Function": {
+		tfType: "aws_lambda_function",
+		attrs:  lambdaAttrs,
+	},
+	"AWS::Serverless::Function": {
+		tfType: "aws_lambda_function",
+		attrs:  lambdaAttrs,
+	},
+	"AWS::DynamoDB::Table": {
+		tfType: "aws_dynamodb_table",
+		attrs: []attrMapping{
+			{cfn: "BillingMode", tf: "billing_mode", kind: attrString},
+		},
+		convert: convertThroughput,
+	},
+	"AWS::Serverless::SimpleTable": {
+		tfType: "aws_dynamodb_table",
+		convert: func(props, config map[string]interface{}) {
+			// Without ProvisionedThroughput a SimpleTable is on-demand
+			if _, ok := props["ProvisionedThroughput"]; !ok {
+				config["billing_mode"] = "PAY_PER_REQUEST"
+			}
+			convertThroughput(props, config)
+		},
+	},
+	"AWS::ElastiCache::CacheCluster": {
+		tfType: "aws_elasticache_cluster",
+		attrs: []attrMapping{
+			{cfn: "CacheNodeType", tf: "node_type", kind: attrString},
+			{cfn: "Engine", tf: "engine", kind: attrString},
+			{cfn: "NumCacheNodes", tf: "num_cache_nodes", kind: attrNumber},
+			{cfn: "SnapshotRetentionLimit", tf: "snapshot_retention_limit", kind: attrNumber},
+		},
+	},
+	"AWS::ElastiCache::ReplicationGroup": {
+		tfType: "aws_elasticache_replication_group",
+		attrs: []attrMapping{
+			{cfn: "CacheNodeType", tf: "node_type", kind: attrString},
+			{cfn: "Engine", tf: "engine", kind: attrString},
+			{cfn: "NumCacheClusters", tf: "num_cache_clusters", kind: attrNumber},
+			{cfn: "SnapshotRetentionLimit", tf: "snapshot_retention_limit", kind: attrNumber},
+		},
+	},
+	"AWS::S3::Bucket":                    {tfType: "aws_s3_bucket"},
+	"AWS::EC2::NatGateway":               {tfType: "aws_nat_gateway"},
+	"AWS::EC2::EIP":                      {tfType: "aws_eip"},
+	"AWS::EC2::VPNGateway":               {tfType: "aws_vpn_gateway"},
+	"AWS::EC2::CustomerGateway":          {tfType: "aws_customer_gateway"},
+	"AWS::EC2::VPCPeeringConnection":     {tfType: "aws_vpc_peering_connection"},
+	"AWS::EC2::TransitGateway":           {tfType: "aws_ec2_transit_gateway"},
+	"AWS::EC2::TransitGatewayAttachment": {tfType: "aws_ec2_transit_gateway_vpc_attachment"},
+	"AWS::EC2::VPCEndpoint": {
+		tfType: "aws_vpc_endpoint",
+		attrs: []attrMapping{
+			{cfn: "VpcEndpointType", tf: "vpc_endpoint_type", kind: attrString},
+		},
+	},
+	"AWS::ElasticLoadBalancingV2::LoadBalancer": {
+		tfType: "aws_lb",
+		attrs: []attrMapping{
+			{cfn: "Type", tf: "load_balancer_type", kind: attrString},
+		},
+	},
+	"AWS::ElasticLoadBalancingV2::Listener": {
+		tfType: "aws_lb_listener",
+	},
+	"AWS::EKS::Cluster": {
+		tfType: "aws_eks_cluster",
+	},
+	"AWS::EKS::Nodegroup": {
+		tfType: "aws_eks_node_group",
+		attrs: []attrMapping{
+			{cfn: "InstanceTypes", tf: "instance_types", kind: attrList},
+			{cfn: "ScalingConfig", tf: "scaling_config", kind: attrBlock, nested: []attrMapping{
+				{cfn: "DesiredSize", tf: "desired_size", kind: attrNumber},
+				{cfn: "MinSize", tf: "min_size", kind: attrNumber},
+				{cfn: "MaxSize", tf: "max_size", kind: attrNumber},
+			}},
+		},
+	},
+	"AWS::EKS::FargateProfile": {
+		tfType: "aws_eks_fargate_profile",
+	},
+}
+
+// mapAttrs converts the properties listed in attrs, skipping values of the
+// wrong type
+func mapAttrs(props map[string]interface{}, attrs []attrMapping) map[string]interface{} {
+	config := make(map[string]interface{})
+	for _, a := range attrs {
+		val, ok := props[a.cfn]
+		if !ok {
+			continue
+		}
+		if converted := convertAttr(val, a); converted != nil {
+			config[a.tf] = converted
+		}
+	}
+	return config
+}
+
+// convertAttr coerces a property value to the attribute's kind.
+// CloudFormation accepts numbers and booleans as strings, e.g.
+// AllocatedStorage: "100".
+func convertAttr(val interface{}, a attrMapping) interface{} {
+	switch a.kind {
+	case attrString:
+		if s, ok := scalarString(val); ok {
+			return s
+		}
+	case attrNumber:
+		if f, ok := toNumber(val).(float64); ok {
+			return f
+		}
+	case attrBool:
+		switch v := val.(type) {
+		case bool:
+			return v
+		case string:
+			if v == "true" || v == "false" {
+				return v == "true"
+			}
+		}
+	case attrList:
+		if list, ok := val.([]interface{}); ok {
+			return list
+		}
+	case attrBlock:
+		if m, ok := val.(map[string]interface{}); ok {
+			return mapAttrs(m, a.nested)
+		}
+	}
+	return nil
+}
+
+// rootDevices are the device names AMIs commonly use for the root volume
+var rootDevices = map[string]bool{
+	"/dev/xvda": true,
+	"/dev/sda1": true,
+	"/dev/sda":  true,
+}
+
+// convertBlockDevices splits BlockDeviceMappings into root_block_device and
+// ebs_block_device by device name
+func convertBlockDevices(props, config map[string]interface{}) {
+	mappings, ok := props["BlockDeviceMappings"].([]interface{})
+	if !ok {
+		return
+	}
+
+	var ebsDevices []interface{}
+	for _, m := range mappings {
+		mapping, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ebs, ok := mapping["Ebs"].(map[string]interface{})
+		if !ok {
+			// Instance store and NoDevice mappings have no EBS cost
+			continue
+		}
+
+		device := mapAttrs(ebs, ebsAttrs)
+		name, _ := mapping["DeviceName"].(string)
+		if _, hasRoot := config["root_block_device"]; rootDevices[name] && !hasRoot {
+			config["root_block_device"] = device
+			continue
+		}
+		device["device_name"] = name
+		ebsDevices = append(ebsDevices, device)
+	}
+	if len(ebsDevices) > 0 {
+		config["ebs_block_device"] = ebsDevices
+	}
+}
+
+// convertThroughput flattens ProvisionedThroughput into read_capacity and
+// write_capacity
+func convertThroughput(props, config map[string]interface{}) {
+	throughput, ok := props["ProvisionedThroughput"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for cfn, tf := range map[string]string{"ReadCapacityUnits": "read_capacity", "WriteCapacityUnits": "write_capacity"} {
+		if f, ok := toNumber(throughput[cfn]).(float64); ok {
+			config[tf] = f
+		}
+	}
+}
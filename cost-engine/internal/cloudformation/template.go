@@ -0,0 +1,423 @@
+// Package cloudformation converts CloudFormation and SAM templates into the
+// plan shape the pricing matchers read
+package cloudformation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// template is the subset of a CloudFormation template we read
+type template struct {
+	Parameters map[string]parameter
+	Mappings   map[string]interface{}
+	Conditions map[string]interface{}
+	Resources  map[string]resource
+	Outputs    map[string]interface{}
+	Globals    map[string]interface{} // SAM only
+}
+
+type parameter struct {
+	Type        string
+	Default     interface{}
+	Description string
+}
+
+type resource struct {
+	Type       string
+	Properties map[string]interface{}
+	Condition  string
+	DependsOn  []string
+	Line       int
+	Column     int
+}
+
+// IsTemplate reports whether JSON content is a CloudFormation template
+// rather than Terraform plan JSON
+func IsTemplate(data []byte) bool {
+	var probe struct {
+		AWSTemplateFormatVersion interface{}            `json:"AWSTemplateFormatVersion"`
+		Transform                interface{}            `json:"Transform"`
+		Resources                map[string]interface{} `json:"Resources"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	return probe.AWSTemplateFormatVersion != nil || probe.Transform != nil || len(probe.Resources) > 0
+}
+
+// Parse converts a CloudFormation or SAM template, in YAML or JSON, into a
+// plan. region is the region the stack is deployed to, the value of
+// AWS::Region. params supplies parameter values, which take precedence over
+// parameter defaults. filename is used for source locations.
+func Parse(data []byte, filename, region string, params map[string]interface{}) (*types.TerraformPlan, error) {
+	tmpl, err := decodeTemplate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &types.TerraformPlan{
+		Resources:   []types.TerraformResource{},
+		Variables:   make(map[string]interface{}),
+		Locals:      make(map[string]interface{}),
+		DataSources: []types.TerraformResource{},
+		Outputs:     make(map[string]interface{}),
+		Modules:     []string{},
+	}
+
+	e := newEvaluator(tmpl, region, params)
+	for name, val := range e.params {
+		plan.Variables[name] = val
+	}
+
+	var ids []string
+	for id := range tmpl.Resources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		res := tmpl.Resources[id]
+		mapping, ok := resourceMappings[res.Type]
+		if !ok {
+			// IAM roles, log groups and other resources with no direct cost
+			continue
+		}
+		address := mapping.tfType + "." + id
+
+		if res.Condition != "" {
+			create, known := e.condition(res.Condition)
+			if !known {
+				e.addDiagnostic(types.SeverityWarning, "Unknown resource condition",
+					fmt.Sprintf("Condition %q could not be evaluated, so the resource is estimated as created.", res.Condition), address)
+			} else if !create {
+				plan.NotCreated = append(plan.NotCreated, types.DisabledResource{
+					Address:   address,
+					Type:      mapping.tfType,
+					Name:      id,
+					Condition: "Condition: " + res.Condition,
+					Reason:    fmt.Sprintf("condition %s is false", res.Condition),
+				})
+				continue
+			}
+		}
+
+		props := res.Properties
+		if strings.HasPrefix(res.Type, "AWS::Serverless::") {
+			props = withGlobals(props, tmpl.Globals, strings.TrimPrefix(res.Type, "AWS::Serverless::"))
+		}
+
+		// Resolve each property separately so parameters without a value
+		// can be traced to the properties that use them
+		resolved := make(map[string]interface{}, len(props))
+		var names []string
+		for name := range props {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			e.used = make(map[string]bool)
+			val := clean(e.resolve(props[name]))
+			for param := range e.used {
+				e.addUsage(param, types.InputUsage{
+					Address:      address,
+					ResourceType: mapping.tfType,
+					Attribute:    name,
+				})
+			}
+			if val != nil {
+				resolved[name] = val
+			}
+		}
+		e.used = nil
+
+		plan.Resources = append(plan.Resources, types.TerraformResource{
+			Type:      mapping.tfType,
+			Name:      id,
+			Address:   address,
+			Provider:  "aws",
+			Config:    mapping.config(resolved),
+			Count:     1,
+			DependsOn: res.DependsOn,
+			Source: &types.SourceRange{
+				Filename:    filename,
+				StartLine:   res.Line,
+				StartColumn: res.Column,
+				EndLine:     res.Line,
+				EndColumn:   res.Column + len(id),
+			},
+		})
+	}
+
+	for name, out := range tmpl.Outputs {
+		if m, ok := out.(map[string]interface{}); ok {
+			if val := clean(e.resolve(m["Value"])); val != nil {
+				plan.Outputs[name] = val
+			}
+		}
+	}
+
+	plan.RequiredInputs = e.requiredInputs()
+	plan.Diagnostics = e.diags
+	return plan, nil
+}
+
+// withGlobals applies the SAM Globals section for a resource kind, e.g.
+// Function, beneath the resource's own properties
+func withGlobals(props, globals map[string]interface{}, kind string) map[string]interface{} {
+	defaults, ok := globals[kind].(map[string]interface{})
+	if !ok {
+		return props
+	}
+	merged := make(map[string]interface{}, len(defaults)+len(props))
+	for name, val := range defaults {
+		merged[name] = val
+	}
+	for name, val := range props {
+		merged[name] = val
+	}
+	return merged
+}
+
+// decodeTemplate reads a YAML or JSON template, expanding short form
+// intrinsic functions such as !Ref and !If into their long form
+func decodeTemplate(data []byte) (*template, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid CloudFormation template: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid CloudFormation template: expected a mapping at the top level")
+	}
+	root := doc.Content[0]
+
+	raw, err := nodeValue(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CloudFormation template: %w", err)
+	}
+	top := raw.(map[string]interface{})
+
+	resources, ok := top["Resources"].(map[string]interface{})
+	if !ok || len(resources) == 0 {
+		return nil, fmt.Errorf("not a CloudFormation template: missing Resources")
+	}
+
+	tmpl := &template{
+		Parameters: make(map[string]parameter),
+		Resources:  make(map[string]resource),
+	}
+	tmpl.Mappings, _ = top["Mappings"].(map[string]interface{})
+	tmpl.Conditions, _ = top["Conditions"].(map[string]interface{})
+	tmpl.Outputs, _ = top["Outputs"].(map[string]interface{})
+	tmpl.Globals, _ = top["Globals"].(map[string]interface{})
+
+	if params, ok := top["Parameters"].(map[string]interface{}); ok {
+		for name, p := range params {
+			m, _ := p.(map[string]interface{})
+			param := parameter{Type: "String", Default: m["Default"]}
+			if t, ok := m["Type"].(string); ok {
+				param.Type = t
+			}
+			if d, ok := m["Description"].(string); ok {
+				param.Description = d
+			}
+			tmpl.Parameters[name] = param
+		}
+	}
+
+	// Logical IDs are located from the node tree for source links
+	locations := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "Resources" {
+			res := root.Content[i+1]
+			for j := 0; j+1 < len(res.Content); j += 2 {
+				locations[res.Content[j].Value] = res.Content[j]
+			}
+		}
+	}
+
+	for id, r := range resources {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		res := resource{}
+		res.Type, _ = m["Type"].(string)
+		res.Properties, _ = m["Properties"].(map[string]interface{})
+		res.Condition, _ = m["Condition"].(string)
+		switch deps := m["DependsOn"].(type) {
+		case string:
+			res.DependsOn = []string{deps}
+		case []interface{}:
+			for _, d := range deps {
+				if s, ok := d.(string); ok {
+					res.DependsOn = append(res.DependsOn, s)
+				}
+			}
+		}
+		if node, ok := locations[id]; ok {
+			res.Line, res.Column = node.Line, node.Column
+		}
+		tmpl.Resources[id] = res
+	}
+
+	return tmpl, nil
+}
+
+// maxTemplateNodes caps the YAML nodes a template may expand to, so nested
+// aliases cannot blow up into billions of values
+const maxTemplateNodes = 1000000
+
+// nodeValue converts a YAML node into plain Go values. Numbers become
+// float64, as in JSON, and short form tags become single-key maps.
+func nodeValue(node *yaml.Node) (interface{}, error) {
+	d := &nodeDecoder{expanding: make(map[*yaml.Node]bool)}
+	return d.value(node)
+}
+
+// nodeDecoder tracks alias expansion while converting a node tree
+type nodeDecoder struct {
+	expanding map[*yaml.Node]bool // Anchored nodes whose alias is being expanded
+	nodes     int
+}
+
+// value converts a node and its children
+func (d *nodeDecoder) value(node *yaml.Node) (interface{}, error) {
+	d.nodes++
+	if d.nodes > maxTemplateNodes {
+		return nil, fmt.Errorf("template expands to more than %d YAML nodes", maxTemplateNodes)
+	}
+
+	var val interface{}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return d.value(node.Content[0])
+	case yaml.AliasNode:
+		if d.expanding[node.Alias] {
+			return nil, fmt.Errorf("line %d: alias *%s refers to itself", node.Line, node.Value)
+		}
+		d.expanding[node.Alias] = true
+		defer delete(d.expanding, node.Alias)
+		return d.value(node.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v, err := d.value(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[node.Content[i].Value] = v
+		}
+		val = m
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			v, err := d.value(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		val = list
+	case yaml.ScalarNode:
+		if isShortForm(node.Tag) {
+			val = node.Value
+			break
+		}
+		if err := node.Decode(&val); err != nil {
+			return nil, err
+		}
+		switch n := val.(type) {
+		case int:
+			val = float64(n)
+		case uint64:
+			val = float64(n)
+		}
+	}
+
+	if !isShortForm(node.Tag) {
+		return val, nil
+	}
+	switch node.Tag {
+	case "!Ref":
+		return map[string]interface{}{"Ref": val}, nil
+	case "!Condition":
+		return map[string]interface{}{"Condition": val}, nil
+	case "!GetAtt":
+		// !GetAtt Resource.Attribute is shorthand for a two-element list
+		if s, ok := val.(string); ok {
+			parts := strings.SplitN(s, ".", 2)
+			list := make([]interface{}, len(parts))
+			for i, p := range parts {
+				list[i] = p
+			}
+			val = list
+		}
+		return map[string]interface{}{"Fn::GetAtt": val}, nil
+	default:
+		return map[string]interface{}{"Fn::" + strings.TrimPrefix(node.Tag, "!"): val}, nil
+	}
+}
+
+// isShortForm reports whether a YAML tag is an intrinsic function shorthand
+func isShortForm(tag string) bool {
+	return strings.HasPrefix(tag, "!") && !strings.HasPrefix(tag, "!!")
+}
+
+// paramValue converts a supplied or default parameter value to its
+// declared type: Number becomes float64 and list types become lists
+func paramValue(paramType string, val interface{}) interface{} {
+	switch {
+	case paramType == "Number":
+		return toNumber(val)
+	case paramType == "CommaDelimitedList" || strings.HasPrefix(paramType, "List<"):
+		var items []interface{}
+		switch v := val.(type) {
+		case []interface{}:
+			items = v
+		case string:
+			for _, s := range strings.Split(v, ",") {
+				items = append(items, strings.TrimSpace(s))
+			}
+		default:
+			return val
+		}
+		if paramType == "List<Number>" {
+			for i, item := range items {
+				items[i] = toNumber(item)
+			}
+		}
+		return items
+	default:
+		if f, ok := val.(float64); ok {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		return val
+	}
+}
+
+// toNumber converts numeric strings to float64, leaving other values as is
+func toNumber(val interface{}) interface{} {
+	switch v := val.(type) {
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return f
+		}
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return val
+}
@@ -0,0 +1,111 @@
+package cloudformation
+
+import "testing"
+
+func TestParsePseudoParameters(t *testing.T) {
+	tmpl := []byte(`
+Mappings:
+  RegionMap:
+    us-east-1:
+      InstanceType: m5.large
+    eu-west-1:
+      InstanceType: t3.small
+Resources:
+  Server:
+    Type: AWS::EC2::Instance
+    Properties:
+      InstanceType: !FindInMap [RegionMap, !Ref AWS::Region, InstanceType]
+      ImageId: !Sub "ami-${AWS::Partition}-${AWS::URLSuffix}"
+`)
+
+	tests := []struct {
+		region       string
+		instanceType interface{}
+		imageID      interface{}
+	}{
+		{"us-east-1", "m5.large", "ami-aws-amazonaws.com"},
+		{"eu-west-1", "t3.small", "ami-aws-amazonaws.com"},
+		{"cn-north-1", nil, "ami-aws-cn-amazonaws.com.cn"},
+		{"", nil, "ami-aws-amazonaws.com"},
+	}
+
+	for _, tt := range tests {
+		plan, err := Parse(tmpl, "template.yaml", tt.region, nil)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.region, err)
+		}
+		if len(plan.Resources) != 1 {
+			t.Fatalf("Parse(%q): got %d resources, want 1", tt.region, len(plan.Resources))
+		}
+		config := plan.Resources[0].Config
+		if got := config["instance_type"]; got != tt.instanceType {
+			t.Errorf("Parse(%q): instance_type = %v, want %v", tt.region, got, tt.instanceType)
+		}
+		if got := config["ami"]; got != tt.imageID {
+			t.Errorf("Parse(%q): ami = %v, want %v", tt.region, got, tt.imageID)
+		}
+	}
+}
+
+func TestFindInMapDefaultValue(t *testing.T) {
+	tmpl := []byte(`
+Parameters:
+  Size:
+    Type: String
+    Default: t3.small
+Mappings:
+  RegionMap:
+    us-east-1:
+      InstanceType: m5.large
+Resources:
+  Server:
+    Type: AWS::EC2::Instance
+    Properties:
+      InstanceType: !FindInMap [RegionMap, !Ref AWS::Region, InstanceType, {DefaultValue: !Ref Size}]
+`)
+
+	for region, want := range map[string]string{"us-east-1": "m5.large", "eu-west-1": "t3.small"} {
+		plan, err := Parse(tmpl, "template.yaml", region, nil)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", region, err)
+		}
+		if got := plan.Resources[0].Config["instance_type"]; got != want {
+			t.Errorf("Parse(%q): instance_type = %v, want %s", region, got, want)
+		}
+	}
+}
+
+func TestParseRejectsRecursiveAliases(t *testing.T) {
+	tmpl := []byte(`
+Resources:
+  A: &a
+    Type: AWS::EC2::Instance
+    Properties: *a
+`)
+	if _, err := Parse(tmpl, "template.yaml", "us-east-1", nil); err == nil {
+		t.Error("self-referencing alias was accepted")
+	}
+}
+
+func TestParseLimitsAliasExpansion(t *testing.T) {
+	// Each level repeats the one before nine times: 9^9 values when expanded
+	tmpl := []byte(`
+a: &a [x, x, x, x, x, x, x, x, x]
+b: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a]
+c: &c [*b, *b, *b, *b, *b, *b, *b, *b, *b]
+d: &d [*c, *c, *c, *c, *c, *c, *c, *c, *c]
+e: &e [*d, *d, *d, *d, *d, *d, *d, *d, *d]
+f: &f [*e, *e, *e, *e, *e, *e, *e, *e, *e]
+g: &g [*f, *f, *f, *f, *f, *f, *f, *f, *f]
+h: &h [*g, *g, *g, *g, *g, *g, *g, *g, *g]
+i: &i [*h, *h, *h, *h, *h, *h, *h, *h, *h]
+Resources:
+  Server:
+    Type: AWS::EC2::Instance
+    Properties:
+      Tags: *i
+`)
+	if _, err := Parse(tmpl, "template.yaml", "us-east-1", nil); err == nil {
+		t.Error("exponentially expanding aliases were accepted")
+	}
+}
@@ -0,0 +1,192 @@
+package pricing
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// DefaultCacheSize is the number of price lookups kept in memory unless
+// configured otherwise
+const DefaultCacheSize = 10000
+
+// CacheStats reports the use of the price lookup cache
+type CacheStats struct {
+	Entries        int     `json:"entries"`
+	Capacity       int     `json:"capacity"`
+	Hits           uint64  `json:"hits"`
+	Misses         uint64  `json:"misses"`
+	HitRate        float64 `json:"hit_rate"`
+	Evictions      uint64  `json:"evictions"`
+	Invalidations  uint64  `json:"invalidations"`   // Flushes after the catalog or spot history changed
+	CatalogVersion string  `json:"catalog_version"` // Fingerprint of the catalog versions and spot history cached against
+}
+
+// priceCache is a bounded LRU cache of price lookups. Entries are only
+// valid for the catalog version they were looked up in; the whole cache
+// is flushed when catalog_versions or the spot price history changes.
+type priceCache struct {
+	mu            sync.Mutex
+	capacity      int
+	entries       map[string]*list.Element
+	order         *list.List // Most recently used first
+	catalog       string
+	hits          uint64
+	misses        uint64
+	evictions     uint64
+	invalidations uint64
+}
+
+// cacheEntry is a cached lookup result
+type cacheEntry struct {
+	key   string
+	value interface{}
+}
+
+// newPriceCache creates a cache holding up to capacity lookups
+func newPriceCache(capacity int) *priceCache {
+	return &priceCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns a cached lookup and records a hit or miss
+func (c *priceCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		c.hits++
+		return el.Value.(*cacheEntry).value, true
+	}
+	c.misses++
+	return nil, false
+}
+
+// put caches a lookup made against a catalog version, unless the catalog
+// has changed since
+func (c *priceCache) put(catalog, key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.capacity <= 0 || catalog == "" || catalog != c.catalog {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.evictions++
+	}
+}
+
+// setVersion flushes the cache when the catalog version has changed
+func (c *priceCache) setVersion(catalog string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if catalog == c.catalog {
+		return
+	}
+	if c.order.Len() > 0 {
+		c.invalidations++
+	}
+	c.catalog = catalog
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// resize changes the capacity, evicting the least recently used lookups
+func (c *priceCache) resize(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.capacity = capacity
+	for c.order.Len() > 0 && c.order.Len() > capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.evictions++
+	}
+}
+
+// stats returns a snapshot of the cache statistics
+func (c *priceCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{
+		Entries:        c.order.Len(),
+		Capacity:       c.capacity,
+		Hits:           c.hits,
+		Misses:         c.misses,
+		Evictions:      c.evictions,
+		Invalidations:  c.invalidations,
+		CatalogVersion: c.catalog,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRate = float64(c.hits) / float64(total)
+	}
+	return stats
+}
+
+// cacheKey identifies a price lookup by service, region, usage type,
+// attributes and term, e.g. OnDemand or Reserved/standard/1yr/no_upfront
+func cacheKey(vector types.UsageVector, term string) string {
+	attrs := make([]string, 0, len(vector.Attributes))
+	for k, v := range vector.Attributes {
+		attrs = append(attrs, k+"="+v)
+	}
+	sort.Strings(attrs)
+	return strings.Join([]string{vector.Service, vector.Region, vector.UsageType, strings.Join(attrs, ","), term}, "|")
+}
+
+// UseCacheSize sets the number of price lookups kept in memory; 0 disables
+// the cache
+func (m *Matcher) UseCacheSize(size int) error {
+	if size < 0 {
+		return fmt.Errorf("cache size must not be negative, got %d", size)
+	}
+	m.cache.resize(size)
+	return nil
+}
+
+// CacheStats returns the statistics of the price lookup cache
+func (m *Matcher) CacheStats() CacheStats {
+	return m.cache.stats()
+}
+
+// syncCache flushes cached lookups when catalog_versions or the imported
+// spot price history has changed and returns the catalog version lookups
+// made now belong to. Lookups are not cached when the version cannot be read.
+func (m *Matcher) syncCache(ctx context.Context) string {
+	var catalog string
+	err := m.pool.QueryRow(ctx, `
+		SELECT COUNT(*)::text || ':' || COALESCE(MAX(id), 0)::text || ':' ||
+		       COALESCE(MAX(ingested_at)::text, '') || ':' ||
+		       COALESCE((SELECT MAX(id) FROM spot_price_history), 0)::text
+		FROM catalog_versions
+		WHERE status = 'completed'
+	`).Scan(&catalog)
+	if err != nil {
+		log.Printf("Warning: catalog version check failed, bypassing price cache: %v", err)
+		catalog = ""
+	}
+	m.cache.setVersion(catalog)
+	return catalog
+}
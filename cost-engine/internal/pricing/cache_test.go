@@ -0,0 +1,96 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+func TestPriceCacheEviction(t *testing.T) {
+	c := newPriceCache(2)
+	c.setVersion("v1")
+
+	c.put("v1", "a", 1)
+	c.put("v1", "b", 2)
+	c.get("a") // a is now the most recently used
+	c.put("v1", "c", 3)
+
+	if _, ok := c.get("b"); ok {
+		t.Error("least recently used entry b was not evicted")
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if got, ok := c.get(key); !ok || got.(int) != want {
+			t.Errorf("get(%q) = %v, %v; want %d", key, got, ok, want)
+		}
+	}
+
+	stats := c.stats()
+	if stats.Entries != 2 || stats.Evictions != 1 || stats.Hits != 3 || stats.Misses != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	c.resize(1)
+	if stats := c.stats(); stats.Entries != 1 || stats.Evictions != 2 {
+		t.Errorf("after resize: stats = %+v", stats)
+	}
+}
+
+func TestPriceCacheVersion(t *testing.T) {
+	c := newPriceCache(10)
+	c.setVersion("v1")
+	c.put("v1", "a", 1)
+
+	// Lookups made against another catalog are not cached
+	c.put("v0", "stale", 0)
+	if _, ok := c.get("stale"); ok {
+		t.Error("lookup from an old catalog was cached")
+	}
+
+	// The same version keeps entries
+	c.setVersion("v1")
+	if _, ok := c.get("a"); !ok {
+		t.Error("entry flushed although the catalog is unchanged")
+	}
+
+	c.setVersion("v2")
+	if _, ok := c.get("a"); ok {
+		t.Error("entry kept after the catalog changed")
+	}
+	if stats := c.stats(); stats.Invalidations != 1 || stats.CatalogVersion != "v2" {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// Nothing is cached while the catalog version is unknown
+	c.setVersion("")
+	c.put("", "a", 1)
+	if _, ok := c.get("a"); ok {
+		t.Error("lookup cached without a catalog version")
+	}
+}
+
+func TestPriceCacheDisabled(t *testing.T) {
+	c := newPriceCache(0)
+	c.setVersion("v1")
+	c.put("v1", "a", 1)
+	if _, ok := c.get("a"); ok {
+		t.Error("lookup cached with a capacity of 0")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	vector := types.UsageVector{
+		Service:    "AmazonEC2",
+		Region:     "us-east-1",
+		UsageType:  "BoxUsage:m5.large",
+		Attributes: map[string]string{"tenancy": "Shared", "operatingSystem": "Linux"},
+	}
+	same := vector
+	same.Attributes = map[string]string{"operatingSystem": "Linux", "tenancy": "Shared"}
+
+	if cacheKey(vector, "OnDemand") != cacheKey(same, "OnDemand") {
+		t.Error("cache key depends on attribute order")
+	}
+	if cacheKey(vector, "OnDemand") == cacheKey(vector, "Reserved/standard/1yr/no_upfront") {
+		t.Error("cache key ignores the term")
+	}
+}
@@ -0,0 +1,269 @@
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// hoursPerMonth is the number of instance hours matchers price per month
+const hoursPerMonth = 730
+
+// reservableUsage are the usage type prefixes of instance hours, the usage
+// Reserved Instances and Savings Plans apply to
+var reservableUsage = []string{"BoxUsage:", "InstanceUsage:", "Multi-AZUsage:", "NodeUsage:"}
+
+// paymentOptions maps payment values to the catalog's PurchaseOption
+var paymentOptions = map[string]string{
+	"no_upfront":      "No Upfront",
+	"partial_upfront": "Partial Upfront",
+	"all_upfront":     "All Upfront",
+}
+
+// offerTermCodes are the AWS offer term codes of Reserved terms by offering
+// class, length and payment. Catalogs ingested before term attributes were
+// stored are matched on the code embedded in rate_code.
+var offerTermCodes = map[string]string{
+	"standard/1yr/no_upfront":         "4NA7Y494T4",
+	"standard/1yr/partial_upfront":    "HU7G6KETJZ",
+	"standard/1yr/all_upfront":        "6QCMYABX3D",
+	"standard/3yr/no_upfront":         "BPH4J8HBKS",
+	"standard/3yr/partial_upfront":    "38NPMPTW36",
+	"standard/3yr/all_upfront":        "NQ3QZPMQV9",
+	"convertible/1yr/no_upfront":      "7NE97W5U4E",
+	"convertible/1yr/partial_upfront": "CUZHX8X6JH",
+	"convertible/1yr/all_upfront":     "VJWZNREJX2",
+	"convertible/3yr/no_upfront":      "Z2E3P23VKM",
+	"convertible/3yr/partial_upfront": "R5XV2EPZQZ",
+	"convertible/3yr/all_upfront":     "MZU6U2429S",
+}
+
+// NormalizePurchaseOption fills in the defaults of a purchase option and
+// reports an error for unsupported values
+func NormalizePurchaseOption(opt *types.PurchaseOption) error {
+	switch opt.Term {
+	case types.PurchaseOnDemand:
+		return nil
+	case types.PurchaseReserved, types.PurchaseComputeSavingsPlan, types.PurchaseInstanceSavingsPlan:
+	default:
+		return fmt.Errorf("unsupported purchase term %q: use on_demand, reserved, compute_savings_plan or ec2_instance_savings_plan", opt.Term)
+	}
+
+	if opt.Length == "" {
+		opt.Length = "1yr"
+	}
+	if opt.Length != "1yr" && opt.Length != "3yr" {
+		return fmt.Errorf("unsupported purchase length %q: use 1yr or 3yr", opt.Length)
+	}
+	if opt.Payment == "" {
+		opt.Payment = "no_upfront"
+	}
+	if _, ok := paymentOptions[opt.Payment]; !ok {
+		return fmt.Errorf("unsupported payment %q: use no_upfront, partial_upfront or all_upfront", opt.Payment)
+	}
+
+	if opt.Term != types.PurchaseReserved {
+		if opt.OfferingClass != "" {
+			return fmt.Errorf("offering_class applies to reserved terms only")
+		}
+		return nil
+	}
+	if opt.OfferingClass == "" {
+		opt.OfferingClass = "standard"
+	}
+	if opt.OfferingClass != "standard" && opt.OfferingClass != "convertible" {
+		return fmt.Errorf("unsupported offering class %q: use standard or convertible", opt.OfferingClass)
+	}
+	return nil
+}
+
+// purchaseLabel describes a purchase option, e.g. "Reserved 1yr Partial
+// Upfront (standard)"
+func purchaseLabel(opt *types.PurchaseOption) string {
+	payment := paymentOptions[opt.Payment]
+	switch opt.Term {
+	case types.PurchaseReserved:
+		return fmt.Sprintf("Reserved %s %s (%s)", opt.Length, payment, opt.OfferingClass)
+	case types.PurchaseComputeSavingsPlan:
+		return fmt.Sprintf("Compute Savings Plan %s %s", opt.Length, payment)
+	default:
+		return fmt.Sprintf("EC2 Instance Savings Plan %s %s", opt.Length, payment)
+	}
+}
+
+// isReservable reports whether a usage type is instance hours
+func isReservable(usageType string) bool {
+	for _, prefix := range reservableUsage {
+		if strings.HasPrefix(usageType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyCommitment reprices an item priced on demand under its vector's
+// purchase option. Savings Plans rates are not in the pricing catalog, so
+// they are priced at the Reserved rate they match: EC2 Instance Savings
+// Plans at Standard and Compute Savings Plans at Convertible. Items the
+// commitment cannot be found for stay on demand, with an assumption.
+func (m *Matcher) applyCommitment(ctx context.Context, catalog string, dim *PricingDimension, item *types.PricedItem) {
+	opt := item.PurchaseOption
+	if opt == nil || opt.Term == types.PurchaseOnDemand || !isReservable(item.UsageType) {
+		return
+	}
+	label := purchaseLabel(opt)
+
+	offeringClass := opt.OfferingClass
+	switch opt.Term {
+	case types.PurchaseInstanceSavingsPlan:
+		offeringClass = "standard"
+	case types.PurchaseComputeSavingsPlan:
+		offeringClass = "convertible"
+	}
+	if opt.Term != types.PurchaseReserved && item.Service != "AmazonEC2" {
+		item.Assumptions = append(item.Assumptions, fmt.Sprintf("%s does not cover %s; priced on demand", label, item.Service))
+		return
+	}
+
+	rows, err := m.reservedRates(ctx, catalog, dim, item.UsageVector, opt.Length, opt.Payment, offeringClass)
+	if err != nil || len(rows) == 0 {
+		if err != nil {
+			log.Printf("Warning: reserved lookup failed for %s: %v", dim.SKU, err)
+		}
+		item.Assumptions = append(item.Assumptions, fmt.Sprintf("No %s rate found for %s; priced on demand", label, item.UsageType))
+		return
+	}
+
+	hourly, upfront, months := commitmentRates(rows, item.Quantity, opt.Length)
+	monthly := hourly*item.Quantity + upfront/months
+
+	var parts []string
+	if hourly > 0 || upfront == 0 {
+		parts = append(parts, fmt.Sprintf("%.2f %s × $%.6f/%s", item.Quantity, item.Unit, hourly, rows[0].Unit))
+	}
+	if upfront > 0 {
+		parts = append(parts, fmt.Sprintf("$%.2f upfront / %.0f months", upfront, months))
+	}
+
+	item.OnDemandMonthlyCost = item.MonthlyCost
+	item.MonthlyCost = monthly
+	item.UpfrontCost = upfront
+	if item.Quantity > 0 {
+		item.PricePerUnit = monthly / item.Quantity
+	}
+	item.Formula = label + ": " + strings.Join(parts, " + ")
+	item.PricingSource = rows[0].SKU
+
+	if opt.Term != types.PurchaseReserved {
+		item.Assumptions = append(item.Assumptions, fmt.Sprintf("%s approximated at the %s Reserved Instance rate; the actual Savings Plans discount may differ", label, offeringClass))
+		if item.MatchConfidence == types.ConfidenceHigh {
+			item.MatchConfidence = types.ConfidenceMedium
+		}
+	}
+}
+
+// commitmentRates returns the hourly rate of a Reserved offer, its upfront
+// fee for the instances quantity hours a month run, and the months of the
+// term the fee is amortized over. Hourly rows are charged per hour; the
+// upfront fee is per instance.
+func commitmentRates(rows []*PricingDimension, quantity float64, length string) (hourly, upfront, months float64) {
+	instances := quantity / hoursPerMonth
+	for _, row := range rows {
+		if row.Unit == "Quantity" {
+			upfront += row.PricePerUnit * instances
+		} else {
+			hourly += row.PricePerUnit
+		}
+	}
+	months = 12
+	if length == "3yr" {
+		months = 36
+	}
+	return hourly, upfront, months
+}
+
+// reservedRates returns the Reserved offer rows of a matched dimension,
+// served from the price cache when the catalog is unchanged
+func (m *Matcher) reservedRates(ctx context.Context, catalog string, dim *PricingDimension, vector types.UsageVector, length, payment, offeringClass string) ([]*PricingDimension, error) {
+	key := cacheKey(vector, strings.Join([]string{"Reserved", offeringClass, length, payment}, "/"))
+	if cached, ok := m.cache.get(key); ok {
+		return cached.([]*PricingDimension), nil
+	}
+
+	rows, err := m.queryReserved(ctx, dim, length, payment, offeringClass)
+	if err == nil {
+		m.cache.put(catalog, key, rows)
+	}
+	return rows, err
+}
+
+// queryReserved returns the price rows of a Reserved offer for a matched
+// on-demand dimension: an hourly rate and, unless there is no upfront
+// payment, an upfront fee. The offer is that of the dimension's SKU or, for
+// EC2, of the instance's Used capacity SKU, since the capacity reservation
+// SKUs priced the same on demand carry no Reserved terms.
+func (m *Matcher) queryReserved(ctx context.Context, dim *PricingDimension, length, payment, offeringClass string) ([]*PricingDimension, error) {
+	terms := `attributes->>'LeaseContractLength' = $2
+		        AND attributes->>'PurchaseOption' = $3
+		        AND COALESCE(attributes->>'OfferingClass', 'standard') = $4`
+	params := []interface{}{dim.ID, length, paymentOptions[payment], offeringClass}
+
+	// Offers without term attributes are found by their rate code instead
+	if code, ok := offerTermCodes[offeringClass+"/"+length+"/"+payment]; ok {
+		terms = "(" + terms + ") OR rate_code LIKE $5"
+		params = append(params, "%."+code+".%")
+	}
+
+	query := `
+		WITH matched AS (
+			SELECT catalog_version_id, sku, service, region_code, attributes
+			FROM pricing_dimensions
+			WHERE id = $1
+		), offer AS (
+			SELECT d.sku
+			FROM pricing_dimensions d, matched m
+			WHERE d.catalog_version_id = m.catalog_version_id
+			  AND d.term_type = 'Reserved'
+			  AND (d.sku = m.sku
+			       OR (m.service = 'AmazonEC2'
+			           AND d.service = m.service
+			           AND d.region_code = m.region_code
+			           AND d.attributes->>'capacitystatus' = 'Used'
+			           AND d.attributes->>'instanceType' = m.attributes->>'instanceType'
+			           AND d.attributes->>'operatingSystem' IS NOT DISTINCT FROM m.attributes->>'operatingSystem'
+			           AND d.attributes->>'tenancy' IS NOT DISTINCT FROM m.attributes->>'tenancy'
+			           AND d.attributes->>'preInstalledSw' IS NOT DISTINCT FROM m.attributes->>'preInstalledSw'
+			           AND d.attributes->>'licenseModel' IS NOT DISTINCT FROM m.attributes->>'licenseModel'))
+			ORDER BY d.sku = m.sku DESC
+			LIMIT 1
+		)
+		SELECT id, service, region_code, usage_type, operation, unit,
+		       price_per_unit, currency, begin_range, end_range, term_type,
+		       sku, description
+		FROM pricing_dimensions
+		WHERE catalog_version_id = (SELECT catalog_version_id FROM matched)
+		  AND sku = (SELECT sku FROM offer)
+		  AND term_type = 'Reserved'
+		  AND (` + terms + `)
+		ORDER BY unit
+	`
+
+	rows, err := m.pool.Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dims []*PricingDimension
+	for rows.Next() {
+		d := &PricingDimension{}
+		if err := rows.Scan(dimensionFields(d)...); err != nil {
+			return nil, err
+		}
+		dims = append(dims, d)
+	}
+	return dims, rows.Err()
+}
@@ -0,0 +1,39 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCommitmentRates(t *testing.T) {
+	hourlyRow := func(price float64) *PricingDimension { return &PricingDimension{Unit: "Hrs", PricePerUnit: price} }
+	upfrontRow := func(price float64) *PricingDimension { return &PricingDimension{Unit: "Quantity", PricePerUnit: price} }
+
+	tests := []struct {
+		name        string
+		rows        []*PricingDimension
+		quantity    float64
+		length      string
+		wantHourly  float64
+		wantUpfront float64
+		wantMonthly float64
+	}{
+		{"no upfront", []*PricingDimension{hourlyRow(0.06)}, 730, "1yr", 0.06, 0, 730 * 0.06},
+		{"partial upfront", []*PricingDimension{upfrontRow(262), hourlyRow(0.03)}, 730, "1yr", 0.03, 262, 730*0.03 + 262.0/12},
+		{"all upfront, 3 years", []*PricingDimension{upfrontRow(1440), hourlyRow(0)}, 730, "3yr", 0, 1440, 40},
+		{"upfront per instance", []*PricingDimension{upfrontRow(100)}, 3 * 730, "1yr", 0, 300, 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hourly, upfront, months := commitmentRates(tt.rows, tt.quantity, tt.length)
+			monthly := hourly*tt.quantity + upfront/months
+			if math.Abs(hourly-tt.wantHourly) > 1e-9 || math.Abs(upfront-tt.wantUpfront) > 1e-9 {
+				t.Errorf("commitmentRates = %g/hr, %g upfront; want %g/hr, %g upfront", hourly, upfront, tt.wantHourly, tt.wantUpfront)
+			}
+			if math.Abs(monthly-tt.wantMonthly) > 1e-9 {
+				t.Errorf("monthly = %g, want %g", monthly, tt.wantMonthly)
+			}
+		})
+	}
+}
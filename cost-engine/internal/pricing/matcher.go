@@ -6,6 +6,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -15,12 +17,18 @@ import (
 
 // Matcher queries the pricing warehouse and matches usage vectors to prices
 type Matcher struct {
-	pool *pgxpool.Pool
+	pool           *pgxpool.Pool
+	spotPercentile float64
+	cache          *priceCache
 }
 
 // NewMatcher creates a new pricing matcher
 func NewMatcher(pool *pgxpool.Pool) *Matcher {
-	return &Matcher{pool: pool}
+	return &Matcher{
+		pool:           pool,
+		spotPercentile: DefaultSpotPercentile,
+		cache:          newPriceCache(DefaultCacheSize),
+	}
 }
 
 // PricingDimension represents a row from the pricing_dimensions table
@@ -42,16 +50,84 @@ type PricingDimension struct {
 
 // Match finds the best pricing match for a usage vector
 func (m *Matcher) Match(ctx context.Context, vector types.UsageVector) (*types.PricedItem, error) {
-	// Try multiple matching strategies
-	dim, score, err := m.findBestMatch(ctx, vector)
+	items, err := m.MatchBatch(ctx, []types.UsageVector{vector})
 	if err != nil {
-		log.Printf("Match error for %s: %v", vector.UsageType, err)
 		return nil, err
 	}
+	return items[0], nil
+}
+
+// MatchBatch finds the best pricing match for each usage vector, returning
+// priced items in the order of the vectors. Lookups are served from the
+// price cache when the catalog is unchanged; for the rest, each matching
+// strategy resolves every vector it applies to in one set-based query, so
+// a batch costs a handful of round trips however many vectors it holds.
+// Only Spot and commitment repricing look up their items one at a time.
+func (m *Matcher) MatchBatch(ctx context.Context, vectors []types.UsageVector) ([]*types.PricedItem, error) {
+	catalog := m.syncCache(ctx)
+
+	// Look up each distinct uncached vector once
+	matches := make([]matchResult, len(vectors))
+	keys := make([]string, len(vectors))
+	missed := make(map[string][]int)
+	var lookups []types.UsageVector
+	for i, vector := range vectors {
+		keys[i] = cacheKey(vector, "OnDemand")
+		if cached, ok := m.cache.get(keys[i]); ok {
+			matches[i] = cached.(matchResult)
+			continue
+		}
+		if _, ok := missed[keys[i]]; !ok {
+			lookups = append(lookups, vector)
+		}
+		missed[keys[i]] = append(missed[keys[i]], i)
+	}
+
+	if len(lookups) > 0 {
+		dims, scores, err := m.findBestMatches(ctx, lookups)
+
+		// Tiered prices, such as S3 storage, data transfer out and Lambda
+		// requests, are charged piecewise across the ranges of the matched SKU
+		tiers, tierErr := m.queryTiers(ctx, dims)
+		if tierErr != nil {
+			log.Printf("Warning: tier lookup failed: %v", tierErr)
+		}
+
+		for j, vector := range lookups {
+			key := cacheKey(vector, "OnDemand")
+			match := matchResult{dim: dims[j], score: scores[j], tiers: tiers[dims[j]]}
+			for _, i := range missed[key] {
+				matches[i] = match
+			}
+			// Failed lookups are retried by the next request
+			if err == nil && tierErr == nil {
+				m.cache.put(catalog, key, match)
+			}
+		}
+	}
+
+	items := make([]*types.PricedItem, len(vectors))
+	for i, vector := range vectors {
+		items[i] = m.price(ctx, catalog, vector, matches[i])
+	}
+	return items, ctx.Err()
+}
+
+// matchResult is the pricing dimension a vector matched, with its score
+// and the price tiers of its SKU
+type matchResult struct {
+	dim   *PricingDimension
+	score float64
+	tiers []*PricingDimension
+}
 
+// price prices a usage vector at its matched dimension. Repricing lookups
+// are cached against the catalog version the batch was synced to.
+func (m *Matcher) price(ctx context.Context, catalog string, vector types.UsageVector, match matchResult) *types.PricedItem {
+	dim, score, tiers := match.dim, match.score, match.tiers
 	if dim == nil {
 		// No match found
-		return &types.PricedItem{
+		item := &types.PricedItem{
 			UsageVector:     vector,
 			PricePerUnit:    0,
 			MonthlyCost:     0,
@@ -60,10 +136,24 @@ func (m *Matcher) Match(ctx context.Context, vector types.UsageVector) (*types.P
 			MatchScore:      0,
 			PricingSource:   "NOT_FOUND",
 			Formula:         "No pricing match found",
-		}, nil
+		}
+		// Spot history prices an instance without an on-demand SKU
+		if vector.Market == types.MarketSpot {
+			m.applySpot(ctx, catalog, item)
+		}
+		return item
 	}
 
 	monthlyCost := vector.Quantity * dim.PricePerUnit
+	pricePerUnit := dim.PricePerUnit
+	formula := fmt.Sprintf("%.2f %s × $%.6f/%s", vector.Quantity, vector.Unit, dim.PricePerUnit, dim.Unit)
+
+	if isTiered(tiers) {
+		monthlyCost, formula = tieredCost(vector.Quantity, vector.Unit, tiers)
+		if vector.Quantity > 0 {
+			pricePerUnit = monthlyCost / vector.Quantity
+		}
+	}
 
 	// Determine match confidence based on score
 	var confidence types.Confidence
@@ -76,160 +166,355 @@ func (m *Matcher) Match(ctx context.Context, vector types.UsageVector) (*types.P
 		confidence = types.ConfidenceLow
 	}
 
-	return &types.PricedItem{
+	item := &types.PricedItem{
 		UsageVector:     vector,
-		PricePerUnit:    dim.PricePerUnit,
+		PricePerUnit:    pricePerUnit,
 		MonthlyCost:     monthlyCost,
 		Currency:        dim.Currency,
 		MatchConfidence: confidence,
 		MatchScore:      score,
 		PricingSource:   dim.SKU,
-		Formula:         fmt.Sprintf("%.2f %s × $%.6f/%s", vector.Quantity, vector.Unit, dim.PricePerUnit, dim.Unit),
-	}, nil
+		Formula:         formula,
+	}
+
+	// Instance hours may be bought on Spot, or priced under a Reserved or
+	// Savings Plans commitment
+	if vector.Market == types.MarketSpot {
+		m.applySpot(ctx, catalog, item)
+	} else {
+		m.applyCommitment(ctx, catalog, dim, item)
+	}
+
+	return item
 }
 
-// findBestMatch searches for the best pricing match using multiple strategies
-func (m *Matcher) findBestMatch(ctx context.Context, vector types.UsageVector) (*PricingDimension, float64, error) {
+// findBestMatches searches for the best pricing match of each vector using
+// multiple strategies, trying each strategy on the vectors still unmatched.
+// A failed strategy is logged and the next one tried; the failure is still
+// returned so the results are not cached.
+func (m *Matcher) findBestMatches(ctx context.Context, vectors []types.UsageVector) ([]*PricingDimension, []float64, error) {
+	dims := make([]*PricingDimension, len(vectors))
+	scores := make([]float64, len(vectors))
+	var failed error
+
+	// pending returns the indexes of unmatched vectors with a usage type prefix
+	pending := func(prefix string) []int {
+		var idx []int
+		for i, vector := range vectors {
+			if dims[i] == nil && strings.HasPrefix(vector.UsageType, prefix) {
+				idx = append(idx, i)
+			}
+		}
+		return idx
+	}
+	record := func(found map[int]*PricingDimension, score float64) {
+		for i, dim := range found {
+			dims[i] = dim
+			scores[i] = score
+		}
+	}
+
 	// Strategy 1: For EC2 BoxUsage, match by instanceType attribute
-	if strings.HasPrefix(vector.UsageType, "BoxUsage:") {
-		instanceType := strings.TrimPrefix(vector.UsageType, "BoxUsage:")
-		dim, err := m.queryEC2ByInstanceType(ctx, vector.Region, instanceType, vector.Attributes)
+	if idx := pending("BoxUsage:"); len(idx) > 0 {
+		found, err := m.queryEC2ByInstanceType(ctx, vectors, idx)
 		if err != nil {
 			log.Printf("EC2 instance query error: %v", err)
-		} else if dim != nil {
-			return dim, 0.95, nil
+			failed = err
 		}
+		record(found, 0.95)
 	}
 
 	// Strategy 2: For EBS, match by volume type in usage_type
-	if strings.HasPrefix(vector.UsageType, "EBS:VolumeUsage.") {
-		volumeType := strings.TrimPrefix(vector.UsageType, "EBS:VolumeUsage.")
-		dim, err := m.queryEBSVolume(ctx, vector.Region, volumeType)
+	if idx := pending("EBS:VolumeUsage."); len(idx) > 0 {
+		found, err := m.queryEBSVolume(ctx, vectors, idx)
 		if err != nil {
 			log.Printf("EBS query error: %v", err)
-		} else if dim != nil {
-			return dim, 0.9, nil
+			failed = err
 		}
+		record(found, 0.9)
 	}
 
 	// Strategy 3: Generic pattern match
-	dim, err := m.queryGenericPattern(ctx, vector.Service, vector.Region, vector.UsageType)
-	if err != nil {
-		log.Printf("Generic pattern error: %v", err)
-	} else if dim != nil {
-		return dim, 0.7, nil
+	if idx := pending(""); len(idx) > 0 {
+		found, err := m.queryGenericPattern(ctx, vectors, idx)
+		if err != nil {
+			log.Printf("Generic pattern error: %v", err)
+			failed = err
+		}
+		record(found, 0.7)
 	}
 
-	return nil, 0, nil
+	return dims, scores, failed
 }
 
 // queryEC2ByInstanceType finds EC2 pricing by instance type and attributes
-func (m *Matcher) queryEC2ByInstanceType(ctx context.Context, region, instanceType string, attrs map[string]string) (*PricingDimension, error) {
-	os := attrs["operatingSystem"]
-	if os == "" {
-		os = "Linux"
-	}
-	tenancy := attrs["tenancy"]
-	if tenancy == "" {
-		tenancy = "Shared"
+func (m *Matcher) queryEC2ByInstanceType(ctx context.Context, vectors []types.UsageVector, idx []int) (map[int]*PricingDimension, error) {
+	regions := make([]string, len(idx))
+	instanceTypes := make([]string, len(idx))
+	oses := make([]string, len(idx))
+	tenancies := make([]string, len(idx))
+	software := make([]string, len(idx))
+	licenses := make([]string, len(idx))
+	for j, i := range idx {
+		attrs := vectors[i].Attributes
+		regions[j] = vectors[i].Region
+		instanceTypes[j] = strings.TrimPrefix(vectors[i].UsageType, "BoxUsage:")
+		oses[j] = attrs["operatingSystem"]
+		if oses[j] == "" {
+			oses[j] = "Linux"
+		}
+		tenancies[j] = attrs["tenancy"]
+		if tenancies[j] == "" {
+			tenancies[j] = "Shared"
+		}
+		software[j] = attrs["preInstalledSw"]
+		licenses[j] = attrs["licenseModel"]
 	}
 
-	// First try: Match using JSONB attributes
-	query := `
-		SELECT id, service, region_code, usage_type, operation, unit, 
-		       price_per_unit, currency, begin_range, end_range, term_type, 
+	// First try: Match using JSONB attributes, narrowed by the pre-installed
+	// software and license model when the machine image is known
+	found, err := m.lookup(ctx, idx, `
+		SELECT id, service, region_code, usage_type, operation, unit,
+		       price_per_unit, currency, begin_range, end_range, term_type,
 		       sku, description
 		FROM pricing_dimensions
 		WHERE service = 'AmazonEC2'
-		  AND region_code = $1
-		  AND attributes->>'instanceType' = $2
-		  AND attributes->>'operatingSystem' = $3
-		  AND attributes->>'tenancy' = $4
+		  AND region_code = v.region
+		  AND attributes->>'instanceType' = v.instance_type
+		  AND attributes->>'operatingSystem' = v.os
+		  AND attributes->>'tenancy' = v.tenancy
+		  AND (v.software = '' OR attributes->>'preInstalledSw' = v.software)
+		  AND (v.license = '' OR attributes->>'licenseModel' = v.license)
 		  AND term_type = 'OnDemand'
 		  AND price_per_unit > 0
 		ORDER BY price_per_unit ASC
 		LIMIT 1
-	`
-
-	dim, err := m.scanDimension(ctx, query, region, instanceType, os, tenancy)
-	if err != nil || dim != nil {
-		return dim, err
+	`, "region, instance_type, os, tenancy, software, license",
+		regions, instanceTypes, oses, tenancies, software, licenses)
+	if err != nil || len(found) == len(idx) {
+		return found, err
 	}
 
 	// Second try: Match any OS with the instance type
-	query2 := `
-		SELECT id, service, region_code, usage_type, operation, unit, 
-		       price_per_unit, currency, begin_range, end_range, term_type, 
+	var rest []int
+	var restRegions, restTypes []string
+	for j, i := range idx {
+		if found[i] == nil {
+			rest = append(rest, i)
+			restRegions = append(restRegions, regions[j])
+			restTypes = append(restTypes, instanceTypes[j])
+		}
+	}
+	anyOS, err := m.lookup(ctx, rest, `
+		SELECT id, service, region_code, usage_type, operation, unit,
+		       price_per_unit, currency, begin_range, end_range, term_type,
 		       sku, description
 		FROM pricing_dimensions
 		WHERE service = 'AmazonEC2'
-		  AND region_code = $1
-		  AND attributes->>'instanceType' = $2
+		  AND region_code = v.region
+		  AND attributes->>'instanceType' = v.instance_type
 		  AND term_type = 'OnDemand'
 		  AND price_per_unit > 0
 		ORDER BY price_per_unit ASC
 		LIMIT 1
-	`
-
-	return m.scanDimension(ctx, query2, region, instanceType)
+	`, "region, instance_type", restRegions, restTypes)
+	for i, dim := range anyOS {
+		found[i] = dim
+	}
+	return found, err
 }
 
 // queryEBSVolume finds EBS volume pricing
-func (m *Matcher) queryEBSVolume(ctx context.Context, region, volumeType string) (*PricingDimension, error) {
-	query := `
-		SELECT id, service, region_code, usage_type, operation, unit, 
-		       price_per_unit, currency, begin_range, end_range, term_type, 
+func (m *Matcher) queryEBSVolume(ctx context.Context, vectors []types.UsageVector, idx []int) (map[int]*PricingDimension, error) {
+	regions := make([]string, len(idx))
+	patterns := make([]string, len(idx))
+	for j, i := range idx {
+		regions[j] = vectors[i].Region
+		patterns[j] = "%" + strings.TrimPrefix(vectors[i].UsageType, "EBS:VolumeUsage.") + "%"
+	}
+
+	return m.lookup(ctx, idx, `
+		SELECT id, service, region_code, usage_type, operation, unit,
+		       price_per_unit, currency, begin_range, end_range, term_type,
 		       sku, description
 		FROM pricing_dimensions
 		WHERE service = 'AmazonEC2'
-		  AND region_code = $1
-		  AND usage_type ILIKE $2
+		  AND region_code = v.region
+		  AND usage_type ILIKE v.pattern
 		  AND term_type = 'OnDemand'
 		  AND price_per_unit > 0
 		ORDER BY price_per_unit ASC
 		LIMIT 1
-	`
-
-	return m.scanDimension(ctx, query, region, "%"+volumeType+"%")
+	`, "region, pattern", regions, patterns)
 }
 
 // queryGenericPattern performs a generic pattern match
-func (m *Matcher) queryGenericPattern(ctx context.Context, service, region, usageType string) (*PricingDimension, error) {
-	query := `
-		SELECT id, service, region_code, usage_type, operation, unit, 
-		       price_per_unit, currency, begin_range, end_range, term_type, 
+func (m *Matcher) queryGenericPattern(ctx context.Context, vectors []types.UsageVector, idx []int) (map[int]*PricingDimension, error) {
+	services := make([]string, len(idx))
+	regions := make([]string, len(idx))
+	patterns := make([]string, len(idx))
+	for j, i := range idx {
+		services[j] = vectors[i].Service
+		regions[j] = vectors[i].Region
+		patterns[j] = "%" + vectors[i].UsageType + "%"
+	}
+
+	return m.lookup(ctx, idx, `
+		SELECT id, service, region_code, usage_type, operation, unit,
+		       price_per_unit, currency, begin_range, end_range, term_type,
 		       sku, description
 		FROM pricing_dimensions
-		WHERE service = $1
-		  AND region_code = $2
-		  AND usage_type ILIKE $3
+		WHERE service = v.service
+		  AND region_code = v.region
+		  AND usage_type ILIKE v.pattern
 		  AND term_type = 'OnDemand'
 		  AND price_per_unit > 0
 		ORDER BY price_per_unit ASC
 		LIMIT 1
+	`, "service, region, pattern", services, regions, patterns)
+}
+
+// lookup runs a single-row dimension query for many vectors at once. The
+// query sees one vector's parameters as the columns of v, which are bound
+// from equal-length text arrays; results are keyed by vector index.
+func (m *Matcher) lookup(ctx context.Context, idx []int, query string, columns string, params ...[]string) (map[int]*PricingDimension, error) {
+	found := make(map[int]*PricingDimension, len(idx))
+	if len(idx) == 0 {
+		return found, nil
+	}
+
+	keys := make([]int32, len(idx))
+	for j, i := range idx {
+		keys[j] = int32(i)
+	}
+	args := []interface{}{keys}
+	arrays := []string{"$1::int[]"}
+	for _, param := range params {
+		args = append(args, param)
+		arrays = append(arrays, fmt.Sprintf("$%d::text[]", len(args)))
+	}
+
+	rows, err := m.pool.Query(ctx, `
+		SELECT v.idx, d.*
+		FROM unnest(`+strings.Join(arrays, ", ")+`) AS v(idx, `+columns+`)
+		CROSS JOIN LATERAL (`+query+`) d
+	`, args...)
+	if err != nil {
+		return found, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i int32
+		dim := &PricingDimension{}
+		if err := rows.Scan(append([]interface{}{&i}, dimensionFields(dim)...)...); err != nil {
+			return found, err
+		}
+		found[int(i)] = dim
+	}
+	return found, rows.Err()
+}
+
+// queryTiers returns every price range of each matched dimension's SKU in
+// the same catalog version, including free tiers, ordered by range
+func (m *Matcher) queryTiers(ctx context.Context, dims []*PricingDimension) (map[*PricingDimension][]*PricingDimension, error) {
+	tiers := make(map[*PricingDimension][]*PricingDimension)
+	byID := make(map[int64][]*PricingDimension)
+	var ids []int64
+	for _, dim := range dims {
+		if dim == nil {
+			continue
+		}
+		if _, ok := byID[dim.ID]; !ok {
+			ids = append(ids, dim.ID)
+		}
+		byID[dim.ID] = append(byID[dim.ID], dim)
+	}
+	if len(ids) == 0 {
+		return tiers, nil
+	}
+
+	query := `
+		SELECT m.id, d.id, d.service, d.region_code, d.usage_type, d.operation, d.unit,
+		       d.price_per_unit, d.currency, d.begin_range, d.end_range, d.term_type,
+		       d.sku, d.description
+		FROM pricing_dimensions m
+		JOIN pricing_dimensions d
+		  ON (d.catalog_version_id, d.sku, d.term_type, d.unit) =
+		     (m.catalog_version_id, m.sku, m.term_type, m.unit)
+		WHERE m.id = ANY($1)
+		ORDER BY m.id, d.begin_range ASC NULLS FIRST
 	`
 
-	return m.scanDimension(ctx, query, service, region, "%"+usageType+"%")
+	rows, err := m.pool.Query(ctx, query, ids)
+	if err != nil {
+		return tiers, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		tier := &PricingDimension{}
+		if err := rows.Scan(append([]interface{}{&id}, dimensionFields(tier)...)...); err != nil {
+			return tiers, err
+		}
+		for _, dim := range byID[id] {
+			tiers[dim] = append(tiers[dim], tier)
+		}
+	}
+	return tiers, rows.Err()
 }
 
-// scanDimension scans a single pricing dimension from a query result
-func (m *Matcher) scanDimension(ctx context.Context, query string, args ...interface{}) (*PricingDimension, error) {
-	row := m.pool.QueryRow(ctx, query, args...)
+// isTiered reports whether dimensions form a price ladder: more than one
+// range, each starting where the previous one ends
+func isTiered(tiers []*PricingDimension) bool {
+	if len(tiers) < 2 {
+		return false
+	}
+	for i, tier := range tiers {
+		if tier.BeginRange == nil {
+			return false
+		}
+		if i > 0 && (tiers[i-1].EndRange == nil || *tiers[i-1].EndRange != *tier.BeginRange) {
+			return false
+		}
+	}
+	return true
+}
 
-	dim := &PricingDimension{}
+// tieredCost charges quantity piecewise across the tiers and returns the
+// cost and a formula showing each tier's contribution. Tiers apply to the
+// quantity of a single usage vector, not to usage summed across resources.
+func tieredCost(quantity float64, unit string, tiers []*PricingDimension) (float64, string) {
+	var cost float64
+	var parts []string
+	for _, tier := range tiers {
+		begin := *tier.BeginRange
+		if quantity <= begin {
+			break
+		}
+		end := quantity
+		rangeEnd := "Inf"
+		if tier.EndRange != nil {
+			end = math.Min(quantity, *tier.EndRange)
+			rangeEnd = strconv.FormatFloat(*tier.EndRange, 'f', -1, 64)
+		}
+		amount := end - begin
+		cost += amount * tier.PricePerUnit
+		parts = append(parts, fmt.Sprintf("%.2f %s × $%.6f/%s [%s-%s]",
+			amount, unit, tier.PricePerUnit, tier.Unit, strconv.FormatFloat(begin, 'f', -1, 64), rangeEnd))
+	}
+	if len(parts) == 0 {
+		return 0, fmt.Sprintf("%.2f %s × $%.6f/%s", quantity, unit, tiers[0].PricePerUnit, tiers[0].Unit)
+	}
+	return cost, strings.Join(parts, " + ")
+}
 
-	err := row.Scan(
+// dimensionFields returns the scan destinations for a pricing dimension row
+func dimensionFields(dim *PricingDimension) []interface{} {
+	return []interface{}{
 		&dim.ID, &dim.Service, &dim.RegionCode, &dim.UsageType, &dim.Operation,
 		&dim.Unit, &dim.PricePerUnit, &dim.Currency, &dim.BeginRange, &dim.EndRange,
 		&dim.TermType, &dim.SKU, &dim.Description,
-	)
-
-	if err != nil {
-		if err.Error() == "no rows in result set" {
-			return nil, nil
-		}
-		return nil, err
 	}
-
-	return dim, nil
 }
@@ -0,0 +1,70 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+)
+
+// tier returns a price tier from begin to end; end < 0 is unbounded
+func tier(begin, end, price float64) *PricingDimension {
+	d := &PricingDimension{Unit: "GB-Mo", PricePerUnit: price, BeginRange: &begin}
+	if end >= 0 {
+		d.EndRange = &end
+	}
+	return d
+}
+
+func TestTieredCost(t *testing.T) {
+	// S3 Standard storage tiers
+	s3 := []*PricingDimension{
+		tier(0, 51200, 0.023),
+		tier(51200, 512000, 0.022),
+		tier(512000, -1, 0.021),
+	}
+
+	tests := []struct {
+		name     string
+		quantity float64
+		tiers    []*PricingDimension
+		want     float64
+	}{
+		{"zero", 0, s3, 0},
+		{"first tier", 100, s3, 100 * 0.023},
+		{"tier boundary", 51200, s3, 51200 * 0.023},
+		{"second tier", 60000, s3, 51200*0.023 + 8800*0.022},
+		{"unbounded tier", 600000, s3, 51200*0.023 + 460800*0.022 + 88000*0.021},
+		{"free tier first", 1500, []*PricingDimension{tier(0, 1000, 0), tier(1000, -1, 0.09)}, 500 * 0.09},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, formula := tieredCost(tt.quantity, "GB-Mo", tt.tiers)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("tieredCost(%g) = %g, want %g", tt.quantity, got, tt.want)
+			}
+			if formula == "" {
+				t.Errorf("tieredCost(%g) returned no formula", tt.quantity)
+			}
+		})
+	}
+}
+
+func TestIsTiered(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiers []*PricingDimension
+		want  bool
+	}{
+		{"single price", []*PricingDimension{tier(0, -1, 0.1)}, false},
+		{"contiguous", []*PricingDimension{tier(0, 10, 0.1), tier(10, -1, 0.05)}, true},
+		{"gap", []*PricingDimension{tier(0, 10, 0.1), tier(20, -1, 0.05)}, false},
+		{"unbounded before last", []*PricingDimension{tier(0, -1, 0.1), tier(10, -1, 0.05)}, false},
+		{"no begin range", []*PricingDimension{{PricePerUnit: 0.1}, {PricePerUnit: 0.05}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := isTiered(tt.tiers); got != tt.want {
+			t.Errorf("%s: isTiered = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package matchers
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// AutoScalingMatcher handles aws_autoscaling_group resources with a mixed
+// instances policy, splitting capacity between on-demand and Spot
+type AutoScalingMatcher struct {
+	pool *pgxpool.Pool
+}
+
+// NewAutoScalingMatcher creates an Auto Scaling matcher
+func NewAutoScalingMatcher(pool *pgxpool.Pool) *AutoScalingMatcher {
+	return &AutoScalingMatcher{pool: pool}
+}
+
+// ServiceName returns the AWS service code
+func (m *AutoScalingMatcher) ServiceName() string {
+	return "AmazonEC2"
+}
+
+// Supports returns true for aws_autoscaling_group resources
+func (m *AutoScalingMatcher) Supports(resourceType string) bool {
+	return resourceType == "aws_autoscaling_group"
+}
+
+// Match generates usage vectors for the instances of a mixed instances
+// group. Groups launching a single launch template are not priced: their
+// instance type is only known to the template.
+func (m *AutoScalingMatcher) Match(ctx context.Context, resource types.TerraformResource, region string) ([]types.UsageVector, error) {
+	policy := configBlock(resource.Config, "mixed_instances_policy")
+	if policy == nil {
+		return nil, nil
+	}
+
+	var instanceTypes []string
+	if lt := configBlock(policy, "launch_template"); lt != nil {
+		for _, override := range configBlocks(lt, "override") {
+			if it, ok := override["instance_type"].(string); ok && it != "" {
+				instanceTypes = append(instanceTypes, it)
+			}
+		}
+	}
+	if len(instanceTypes) == 0 {
+		return nil, nil
+	}
+
+	// Capacity: desired_capacity, falling back to min_size
+	var assumptions []string
+	capacity, ok := configNumber(resource.Config, "desired_capacity")
+	if !ok {
+		capacity, _ = configNumber(resource.Config, "min_size")
+		assumptions = append(assumptions, fmt.Sprintf("Assumed min_size of %.0f instances (desired_capacity not set)", capacity))
+	}
+
+	// On-demand base capacity is filled first; the percentage above it
+	// (default 100%) is on demand and the rest Spot
+	base, percentage := 0.0, 100.0
+	if dist := configBlock(policy, "instances_distribution"); dist != nil {
+		if v, ok := configNumber(dist, "on_demand_base_capacity"); ok {
+			base = v
+		}
+		if v, ok := configNumber(dist, "on_demand_percentage_above_base_capacity"); ok {
+			percentage = v
+		}
+	}
+	base = math.Min(base, capacity)
+	onDemand := base + math.Ceil((capacity-base)*percentage/100)
+	spot := capacity - onDemand
+
+	vector := func(instanceType string, instances float64, market types.Market) types.UsageVector {
+		return types.UsageVector{
+			Service:   "AmazonEC2",
+			Region:    region,
+			UsageType: "BoxUsage:" + instanceType,
+			Unit:      "Hrs",
+			Quantity:  730 * instances,
+			Attributes: map[string]string{
+				"instanceType":    instanceType,
+				"operatingSystem": "Linux",
+				"preInstalledSw":  "NA",
+				"tenancy":         "Shared",
+			},
+			Market:      market,
+			Assumptions: append([]string{}, assumptions...),
+		}
+	}
+
+	vectors := []types.UsageVector{}
+	if onDemand > 0 {
+		// The prioritized allocation strategy launches the first override
+		v := vector(instanceTypes[0], onDemand, "")
+		v.Assumptions = append(v.Assumptions, fmt.Sprintf("%.0f on-demand instances of %s, the first override", onDemand, instanceTypes[0]))
+		vectors = append(vectors, v)
+	}
+	if spot > 0 {
+		share := spot / float64(len(instanceTypes))
+		for _, instanceType := range instanceTypes {
+			v := vector(instanceType, share, types.MarketSpot)
+			v.Assumptions = append(v.Assumptions, fmt.Sprintf("%.0f Spot instances spread evenly across %d override instance types", spot, len(instanceTypes)))
+			vectors = append(vectors, v)
+		}
+	}
+	return vectors, nil
+}
@@ -10,7 +10,7 @@ import (
 	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
 )
 
-// EC2Matcher handles aws_instance resources
+// EC2Matcher handles aws_instance and aws_spot_instance_request resources
 type EC2Matcher struct {
 	pool *pgxpool.Pool
 }
@@ -25,9 +25,9 @@ func (m *EC2Matcher) ServiceName() string {
 	return "AmazonEC2"
 }
 
-// Supports returns true for aws_instance resources
+// Supports returns true for aws_instance and aws_spot_instance_request resources
 func (m *EC2Matcher) Supports(resourceType string) bool {
-	return resourceType == "aws_instance"
+	return resourceType == "aws_instance" || resourceType == "aws_spot_instance_request"
 }
 
 // Match generates usage vectors for an EC2 instance
@@ -47,19 +47,29 @@ func (m *EC2Matcher) Match(ctx context.Context, resource types.TerraformResource
 			os = "Windows"
 		}
 	}
+	attributes := map[string]string{
+		"instanceType":    instanceType,
+		"operatingSystem": os,
+		"preInstalledSw":  "NA",
+		"tenancy":         "Shared",
+	}
+
+	// The resolved machine image, when known, decides the OS and licensing
+	if p := resource.Platform; p != nil {
+		attributes["operatingSystem"] = p.OperatingSystem
+		attributes["preInstalledSw"] = p.PreInstalledSw
+		attributes["licenseModel"] = p.LicenseModel
+	}
 
-	// Compute hours (730 hours/month)
+	// Compute hours (730 hours/month), on demand or on Spot
 	vectors = append(vectors, types.UsageVector{
-		Service:   "AmazonEC2",
-		Region:    region,
-		UsageType: "BoxUsage:" + instanceType,
-		Unit:      "Hrs",
-		Quantity:  730,
-		Attributes: map[string]string{
-			"instanceType":    instanceType,
-			"operatingSystem": os,
-			"tenancy":         "Shared",
-		},
+		Service:    "AmazonEC2",
+		Region:     region,
+		UsageType:  "BoxUsage:" + instanceType,
+		Unit:       "Hrs",
+		Quantity:   730,
+		Attributes: attributes,
+		Market:     instanceMarket(resource),
 	})
 
 	// Root EBS volume
@@ -3,6 +3,7 @@ package matchers
 
 import (
 	"context"
+	"strings"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 
@@ -68,6 +69,12 @@ func (m *EKSMatcher) Match(ctx context.Context, resource types.TerraformResource
 			}
 		}
 
+		// Node groups run on demand unless capacity_type is SPOT
+		var market types.Market
+		if ct, ok := resource.Config["capacity_type"].(string); ok && strings.EqualFold(ct, "SPOT") {
+			market = types.MarketSpot
+		}
+
 		// Add EC2 compute cost for each instance type
 		for _, instanceType := range instanceTypes {
 			vectors = append(vectors, types.UsageVector{
@@ -81,6 +88,7 @@ func (m *EKSMatcher) Match(ctx context.Context, resource types.TerraformResource
 					"operatingSystem": "Linux",
 					"tenancy":         "Shared",
 				},
+				Market: market,
 			})
 		}
 
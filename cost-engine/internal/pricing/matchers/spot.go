@@ -0,0 +1,62 @@
+package matchers
+
+import (
+	"strings"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// instanceMarket returns the capacity market of an aws_instance or
+// aws_spot_instance_request: Spot when the instance is a spot request or
+// sets instance_market_options { market_type = "spot" }
+func instanceMarket(resource types.TerraformResource) types.Market {
+	if resource.Type == "aws_spot_instance_request" {
+		return types.MarketSpot
+	}
+	if opts := configBlock(resource.Config, "instance_market_options"); opts != nil {
+		if marketType, ok := opts["market_type"].(string); ok && strings.EqualFold(marketType, "spot") {
+			return types.MarketSpot
+		}
+	}
+	return ""
+}
+
+// configBlock returns a nested block of a resource configuration, which is
+// a map for a single block or a list of maps in plan JSON
+func configBlock(config map[string]interface{}, key string) map[string]interface{} {
+	blocks := configBlocks(config, key)
+	if len(blocks) == 0 {
+		return nil
+	}
+	return blocks[0]
+}
+
+// configBlocks returns the repeated nested blocks of a resource configuration
+func configBlocks(config map[string]interface{}, key string) []map[string]interface{} {
+	switch val := config[key].(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{val}
+	case []interface{}:
+		var blocks []map[string]interface{}
+		for _, item := range val {
+			if block, ok := item.(map[string]interface{}); ok {
+				blocks = append(blocks, block)
+			}
+		}
+		return blocks
+	}
+	return nil
+}
+
+// configNumber returns a numeric configuration value
+func configNumber(config map[string]interface{}, key string) (float64, bool) {
+	switch val := config[key].(type) {
+	case float64:
+		return val, true
+	case int64:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	}
+	return 0, false
+}
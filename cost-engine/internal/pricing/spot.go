@@ -0,0 +1,128 @@
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// DefaultSpotPercentile is the percentile of spot price history Spot usage
+// is priced at unless configured otherwise
+const DefaultSpotPercentile = 50
+
+// spotLookbackDays is the window of spot price history, ending at the most
+// recent imported price, that percentiles are taken over
+const spotLookbackDays = 90
+
+// UseSpotPercentile sets the percentile (0-100] of spot price history Spot
+// usage is priced at
+func (m *Matcher) UseSpotPercentile(percentile float64) error {
+	if percentile <= 0 || percentile > 100 {
+		return fmt.Errorf("spot percentile must be greater than 0 and at most 100, got %g", percentile)
+	}
+	m.spotPercentile = percentile
+	return nil
+}
+
+// applySpot reprices an item at the configured percentile of the imported
+// spot price history for its instance type, operating system and region.
+// Items without history keep their on-demand price, if any, with an
+// assumption.
+func (m *Matcher) applySpot(ctx context.Context, catalog string, item *types.PricedItem) {
+	instanceType, ok := spotInstanceType(item.UsageType)
+	if item.Market != types.MarketSpot || !ok {
+		return
+	}
+	os := item.Attributes["operatingSystem"]
+	if os == "" {
+		os = "Linux"
+	}
+
+	price, samples, err := m.spotPrice(ctx, catalog, item.UsageVector, instanceType, os)
+	if err != nil {
+		log.Printf("Warning: spot price lookup failed for %s: %v", instanceType, err)
+	}
+	if err != nil || samples == 0 {
+		if item.PricingSource != "NOT_FOUND" {
+			item.Assumptions = append(item.Assumptions, fmt.Sprintf("No Spot price history for %s (%s) in %s; priced on demand", instanceType, os, item.Region))
+		}
+		return
+	}
+
+	// Without an on-demand match there is no price to compare against
+	if item.PricingSource != "NOT_FOUND" {
+		item.OnDemandMonthlyCost = item.MonthlyCost
+	}
+	item.MonthlyCost = item.Quantity * price
+	item.PricePerUnit = price
+	item.Formula = fmt.Sprintf("Spot p%g: %.2f %s × $%.6f/%s", m.spotPercentile, item.Quantity, item.Unit, price, item.Unit)
+	item.PricingSource = "spot_price_history"
+	item.Assumptions = append(item.Assumptions, fmt.Sprintf("Spot priced at the p%g of %d price samples for %s (%s) in %s; interruptions are not modeled", m.spotPercentile, samples, instanceType, os, item.Region))
+	if item.MatchConfidence == types.ConfidenceHigh || item.MatchConfidence == types.ConfidenceUnknown {
+		item.MatchConfidence = types.ConfidenceMedium
+	}
+}
+
+// spotInstanceType returns the instance type of a BoxUsage usage type,
+// ignoring a regional prefix such as USE2-BoxUsage:m5.large
+func spotInstanceType(usageType string) (string, bool) {
+	if i := strings.Index(usageType, "-"); i >= 0 && !strings.Contains(usageType[:i], ":") {
+		usageType = usageType[i+1:]
+	}
+	if !strings.HasPrefix(usageType, "BoxUsage:") {
+		return "", false
+	}
+	return strings.TrimPrefix(usageType, "BoxUsage:"), true
+}
+
+// spotResult is a cached spot price lookup
+type spotResult struct {
+	price   float64
+	samples int
+}
+
+// spotPrice returns the spot price percentile of an instance type, served
+// from the price cache when the catalog and spot history are unchanged
+func (m *Matcher) spotPrice(ctx context.Context, catalog string, vector types.UsageVector, instanceType, os string) (float64, int, error) {
+	key := cacheKey(vector, fmt.Sprintf("Spot/p%g", m.spotPercentile))
+	if cached, ok := m.cache.get(key); ok {
+		result := cached.(spotResult)
+		return result.price, result.samples, nil
+	}
+
+	price, samples, err := m.querySpotPrice(ctx, vector.Region, instanceType, os)
+	if err == nil {
+		m.cache.put(catalog, key, spotResult{price: price, samples: samples})
+	}
+	return price, samples, err
+}
+
+// querySpotPrice returns the configured percentile of the spot prices of an
+// instance type across a region's availability zones, and the number of
+// price samples it was taken over
+func (m *Matcher) querySpotPrice(ctx context.Context, region, instanceType, os string) (float64, int, error) {
+	query := `
+		WITH samples AS (
+			SELECT spot_price, timestamp
+			FROM spot_price_history
+			WHERE region_code = $1
+			  AND instance_type = $2
+			  AND operating_system = $3
+		)
+		SELECT COALESCE(percentile_cont($4::float8) WITHIN GROUP (ORDER BY spot_price::float8), 0),
+		       COUNT(*)
+		FROM samples
+		WHERE timestamp >= (SELECT MAX(timestamp) FROM samples) - make_interval(days => $5)
+	`
+
+	var price float64
+	var samples int
+	err := m.pool.QueryRow(ctx, query, region, instanceType, os, m.spotPercentile/100, spotLookbackDays).Scan(&price, &samples)
+	if err != nil {
+		return 0, 0, err
+	}
+	return price, samples, nil
+}
@@ -0,0 +1,26 @@
+package pricing
+
+import "testing"
+
+func TestSpotInstanceType(t *testing.T) {
+	tests := []struct {
+		usageType    string
+		instanceType string
+		ok           bool
+	}{
+		{"BoxUsage:m5.large", "m5.large", true},
+		{"USE2-BoxUsage:m5.large", "m5.large", true},
+		{"EUC1-BoxUsage:c6g.xlarge", "c6g.xlarge", true},
+		{"BoxUsage:u-6tb1.metal", "u-6tb1.metal", true},
+		{"USE2-DedicatedUsage:m5.large", "", false},
+		{"EBS:VolumeUsage.gp3", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		instanceType, ok := spotInstanceType(tt.usageType)
+		if instanceType != tt.instanceType || ok != tt.ok {
+			t.Errorf("spotInstanceType(%q) = %q, %v; want %q, %v", tt.usageType, instanceType, ok, tt.instanceType, tt.ok)
+		}
+	}
+}
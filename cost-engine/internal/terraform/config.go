@@ -0,0 +1,142 @@
+package terraform
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// configBlock is a configuration block read from either native syntax
+// (.tf) or JSON syntax (.tf.json), so the loader can evaluate both the same way
+type configBlock struct {
+	Type     string
+	Labels   []string
+	Body     *configBody
+	DefRange hcl.Range
+}
+
+// configBody holds the attributes and nested blocks of a configuration block.
+// In JSON syntax nested blocks other than dynamic blocks cannot be told apart
+// from object attributes, so they appear as attributes and evaluate to the
+// same map/list shape.
+type configBody struct {
+	Attributes hcl.Attributes
+	Blocks     []*configBlock
+}
+
+// nativeBlock converts a native syntax block
+func nativeBlock(block *hclsyntax.Block) *configBlock {
+	return &configBlock{
+		Type:     block.Type,
+		Labels:   block.Labels,
+		Body:     nativeBody(block.Body),
+		DefRange: block.DefRange(),
+	}
+}
+
+// nativeBody converts a native syntax body
+func nativeBody(body *hclsyntax.Body) *configBody {
+	cb := &configBody{Attributes: make(hcl.Attributes, len(body.Attributes))}
+	for name, attr := range body.Attributes {
+		cb.Attributes[name] = attr.AsHCLAttribute()
+	}
+	for _, block := range body.Blocks {
+		cb.Blocks = append(cb.Blocks, nativeBlock(block))
+	}
+	return cb
+}
+
+// jsonFileSchema describes the top-level blocks read from .tf.json files
+var jsonFileSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "locals"},
+		{Type: "resource", LabelNames: []string{"type", "name"}},
+		{Type: "data", LabelNames: []string{"type", "name"}},
+		{Type: "output", LabelNames: []string{"name"}},
+		{Type: "module", LabelNames: []string{"name"}},
+		{Type: "provider", LabelNames: []string{"name"}},
+	},
+}
+
+// jsonBlocks decodes the top-level blocks of a JSON syntax file. Unknown
+// properties, such as "//" comments and terraform settings, are ignored.
+func jsonBlocks(body hcl.Body) ([]*configBlock, hcl.Diagnostics) {
+	content, _, diags := body.PartialContent(jsonFileSchema)
+	if content == nil {
+		return nil, diags
+	}
+
+	var blocks []*configBlock
+	for _, block := range content.Blocks {
+		var cb *configBody
+		var bodyDiags hcl.Diagnostics
+		if block.Type == "locals" {
+			// Every property of locals is a value, even one named dynamic
+			attrs, attrDiags := block.Body.JustAttributes()
+			cb, bodyDiags = &configBody{Attributes: attrs}, attrDiags
+		} else {
+			cb, bodyDiags = jsonBody(block.Body)
+		}
+		diags = append(diags, bodyDiags...)
+
+		blocks = append(blocks, &configBlock{
+			Type:     block.Type,
+			Labels:   block.Labels,
+			Body:     cb,
+			DefRange: block.DefRange,
+		})
+	}
+	return blocks, diags
+}
+
+// jsonDynamicSchema extracts dynamic blocks from a JSON body
+var jsonDynamicSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "dynamic", LabelNames: []string{"type"}}},
+}
+
+// jsonContentSchema extracts the content block of a JSON dynamic block
+var jsonContentSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "content"}},
+}
+
+// jsonBody decodes a JSON block body: dynamic blocks become nested blocks
+// and every other property becomes an attribute
+func jsonBody(body hcl.Body) (*configBody, hcl.Diagnostics) {
+	content, remain, diags := body.PartialContent(jsonDynamicSchema)
+
+	attrs, attrDiags := remain.JustAttributes()
+	diags = append(diags, attrDiags...)
+	cb := &configBody{Attributes: attrs}
+
+	if content == nil {
+		return cb, diags
+	}
+	for _, block := range content.Blocks {
+		dynContent, dynRemain, dynDiags := block.Body.PartialContent(jsonContentSchema)
+		diags = append(diags, dynDiags...)
+
+		dynAttrs, dynAttrDiags := dynRemain.JustAttributes()
+		diags = append(diags, dynAttrDiags...)
+		dynBody := &configBody{Attributes: dynAttrs}
+
+		if dynContent != nil {
+			for _, contentBlock := range dynContent.Blocks {
+				contentBody, contentDiags := jsonBody(contentBlock.Body)
+				diags = append(diags, contentDiags...)
+				dynBody.Blocks = append(dynBody.Blocks, &configBlock{
+					Type:     contentBlock.Type,
+					Body:     contentBody,
+					DefRange: contentBlock.DefRange,
+				})
+			}
+		}
+
+		cb.Blocks = append(cb.Blocks, &configBlock{
+			Type:     block.Type,
+			Labels:   block.Labels,
+			Body:     dynBody,
+			DefRange: block.DefRange,
+		})
+	}
+	return cb, diags
+}
@@ -0,0 +1,495 @@
+package terraform
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// DataSourceResolver returns the attributes Terraform would read for a data
+// source at plan time, or false if they cannot be known offline. The data
+// source's Config holds its evaluated arguments and Region its provider region.
+type DataSourceResolver func(ds types.TerraformResource) (map[string]interface{}, bool)
+
+// Metadata is the AWS account and catalog information data sources are
+// resolved from, so estimates never call AWS
+type Metadata struct {
+	AccountID         string              `json:"account_id,omitempty"`
+	AvailabilityZones map[string][]string `json:"availability_zones"` // Region -> zone names
+	Images            []Image             `json:"images"`
+}
+
+// Image is a machine image that aws_ami data sources and ami arguments may resolve to
+type Image struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	OwnerID         string `json:"owner_id"`
+	OwnerAlias      string `json:"owner_alias,omitempty"` // e.g. amazon
+	Region          string `json:"region,omitempty"`      // Empty matches any region
+	Architecture    string `json:"architecture,omitempty"`
+	PlatformDetails string `json:"platform_details"` // e.g. Windows, Red Hat Enterprise Linux
+	UsageOperation  string `json:"usage_operation,omitempty"`
+	CreationDate    string `json:"creation_date,omitempty"`
+}
+
+//go:embed aws_metadata.json
+var defaultMetadata []byte
+
+// DefaultMetadata returns the built-in metadata: the availability zones of
+// the default pricing regions and no images
+func DefaultMetadata() *Metadata {
+	meta := &Metadata{}
+	if err := json.Unmarshal(defaultMetadata, meta); err != nil {
+		panic(fmt.Sprintf("invalid built-in metadata: %v", err))
+	}
+	return meta
+}
+
+// builtinMetadata is the built-in metadata shared, read-only, by loaders
+var builtinMetadata = sync.OnceValue(DefaultMetadata)
+
+// LoadMetadata reads a metadata fixture file and layers it over the
+// built-in metadata: its zones replace those of the same region and its
+// images are added
+func LoadMetadata(path string) (*Metadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixture Metadata
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid metadata file %s: %w", path, err)
+	}
+
+	meta := DefaultMetadata()
+	if fixture.AccountID != "" {
+		meta.AccountID = fixture.AccountID
+	}
+	for region, zones := range fixture.AvailabilityZones {
+		meta.AvailabilityZones[region] = zones
+	}
+	meta.Images = append(meta.Images, fixture.Images...)
+	return meta, nil
+}
+
+// UseMetadata registers the built-in resolvers for aws_region,
+// aws_availability_zones, aws_caller_identity and aws_ami backed by meta
+func (l *Loader) UseMetadata(meta *Metadata) {
+	l.metadata = meta
+	l.RegisterDataSource("aws_region", resolveRegion)
+	l.RegisterDataSource("aws_availability_zones", meta.resolveAvailabilityZones)
+	l.RegisterDataSource("aws_caller_identity", meta.resolveCallerIdentity)
+	l.RegisterDataSource("aws_ami", meta.resolveAMI)
+}
+
+// RegisterDataSource sets the resolver for a data source type, replacing
+// any existing one
+func (l *Loader) RegisterDataSource(dataType string, resolver DataSourceResolver) {
+	if l.resolvers == nil {
+		l.resolvers = make(map[string]DataSourceResolver)
+	}
+	l.resolvers[dataType] = resolver
+}
+
+// resolveDataSource returns the value of one data source instance: its
+// arguments plus the attributes its resolver supplies. Data sources
+// without a resolver are unknown.
+func (l *Loader) resolveDataSource(ds types.TerraformResource) cty.Value {
+	resolver, ok := l.resolvers[ds.Type]
+	if !ok {
+		return cty.DynamicVal
+	}
+	attrs, ok := resolver(ds)
+	if !ok {
+		return cty.DynamicVal
+	}
+
+	merged := make(map[string]interface{}, len(ds.Config)+len(attrs))
+	for name, val := range ds.Config {
+		merged[name] = val
+	}
+	for name, val := range attrs {
+		merged[name] = val
+	}
+
+	val, err := goToCty(merged)
+	if err != nil {
+		return cty.DynamicVal
+	}
+	return val
+}
+
+// resolveRegion resolves aws_region to the region requested by its name
+// argument or, failing that, its provider's region
+func resolveRegion(ds types.TerraformResource) (map[string]interface{}, bool) {
+	region := ds.Region
+	for _, arg := range []string{"region", "name"} {
+		if r, ok := ds.Config[arg].(string); ok && r != "" {
+			region = r
+		}
+	}
+	if region == "" {
+		return nil, false
+	}
+	return map[string]interface{}{
+		"id":       region,
+		"name":     region,
+		"region":   region,
+		"endpoint": "ec2." + region + ".amazonaws.com",
+	}, true
+}
+
+// resolveAvailabilityZones lists the zones of the provider's region,
+// honouring exclude_names
+func (m *Metadata) resolveAvailabilityZones(ds types.TerraformResource) (map[string]interface{}, bool) {
+	zones, ok := m.AvailabilityZones[ds.Region]
+	if !ok {
+		return nil, false
+	}
+
+	excluded := make(map[string]bool)
+	if names, ok := ds.Config["exclude_names"].([]interface{}); ok {
+		for _, n := range names {
+			if s, ok := n.(string); ok {
+				excluded[s] = true
+			}
+		}
+	}
+
+	names := []interface{}{}
+	for _, zone := range zones {
+		if !excluded[zone] {
+			names = append(names, zone)
+		}
+	}
+	return map[string]interface{}{
+		"id":          ds.Region,
+		"names":       names,
+		"group_names": []interface{}{ds.Region},
+	}, true
+}
+
+// resolveCallerIdentity resolves aws_caller_identity when an account ID is configured
+func (m *Metadata) resolveCallerIdentity(ds types.TerraformResource) (map[string]interface{}, bool) {
+	if m.AccountID == "" {
+		return nil, false
+	}
+	return map[string]interface{}{
+		"id":         m.AccountID,
+		"account_id": m.AccountID,
+		"arn":        "arn:aws:iam::" + m.AccountID + ":root",
+		"user_id":    m.AccountID,
+	}, true
+}
+
+// resolveAMI picks the image an aws_ami data source would find among the
+// known images, applying its owners, name_regex and filter arguments
+func (m *Metadata) resolveAMI(ds types.TerraformResource) (map[string]interface{}, bool) {
+	var owners []string
+	if list, ok := ds.Config["owners"].([]interface{}); ok {
+		for _, o := range list {
+			if s, ok := o.(string); ok {
+				owners = append(owners, s)
+			}
+		}
+	}
+
+	var nameRegex *regexp.Regexp
+	if pattern, ok := ds.Config["name_regex"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, false
+		}
+		nameRegex = re
+	}
+	filters := amiFilters(ds.Config)
+
+	var matches []Image
+	for _, img := range m.Images {
+		if img.Region != "" && ds.Region != "" && img.Region != ds.Region {
+			continue
+		}
+		if len(owners) > 0 && !containsString(owners, img.OwnerID) && !containsString(owners, img.OwnerAlias) {
+			continue
+		}
+		if nameRegex != nil && !nameRegex.MatchString(img.Name) {
+			continue
+		}
+		if !img.matches(filters) {
+			continue
+		}
+		matches = append(matches, img)
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	// most_recent picks the newest image; Terraform requires it when
+	// several match, so the newest is the best guess either way
+	sort.Slice(matches, func(i, j int) bool { return matches[i].CreationDate > matches[j].CreationDate })
+	img := matches[0]
+
+	platform := ""
+	if strings.HasPrefix(img.PlatformDetails, "Windows") {
+		platform = "windows"
+	}
+	return map[string]interface{}{
+		"id":               img.ID,
+		"image_id":         img.ID,
+		"name":             img.Name,
+		"owner_id":         img.OwnerID,
+		"architecture":     img.Architecture,
+		"platform":         platform,
+		"platform_details": img.PlatformDetails,
+		"usage_operation":  img.UsageOperation,
+		"creation_date":    img.CreationDate,
+	}, true
+}
+
+// amiFilters collects the filter blocks of an aws_ami data source by name
+func amiFilters(config map[string]interface{}) map[string][]string {
+	var blocks []interface{}
+	switch f := config["filter"].(type) {
+	case map[string]interface{}:
+		blocks = []interface{}{f}
+	case []interface{}:
+		blocks = f
+	}
+
+	filters := make(map[string][]string)
+	for _, b := range blocks {
+		block, ok := b.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := block["name"].(string)
+		values, _ := block["values"].([]interface{})
+		for _, v := range values {
+			if s, ok := v.(string); ok {
+				filters[name] = append(filters[name], s)
+			}
+		}
+	}
+	return filters
+}
+
+// matches reports whether the image satisfies every filter it has a value
+// for. Filters on properties not in the fixture are ignored.
+func (img Image) matches(filters map[string][]string) bool {
+	props := map[string]string{
+		"name":             img.Name,
+		"image-id":         img.ID,
+		"owner-id":         img.OwnerID,
+		"owner-alias":      img.OwnerAlias,
+		"architecture":     img.Architecture,
+		"platform-details": img.PlatformDetails,
+		"usage-operation":  img.UsageOperation,
+	}
+	for name, values := range filters {
+		prop, ok := props[name]
+		if !ok || prop == "" {
+			continue
+		}
+		matched := false
+		for _, pattern := range values {
+			if globMatch(pattern, prop) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// globMatch matches EC2 filter wildcards: * for any run of characters and
+// ? for a single character
+func globMatch(pattern, s string) bool {
+	var re strings.Builder
+	re.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.MustCompile(re.String()).MatchString(s)
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// amiPlatformRules infer the platform of an image from its name, in order,
+// when it is not in the fixture table. Each rule lists fragments that must
+// all appear in the lower-cased name.
+var amiPlatformRules = []struct {
+	fragments       []string
+	platformDetails string
+}{
+	{[]string{"windows", "sql", "enterprise"}, "Windows with SQL Server Enterprise"},
+	{[]string{"windows", "sql", "standard"}, "Windows with SQL Server Standard"},
+	{[]string{"windows", "sql", "web"}, "Windows with SQL Server Web"},
+	{[]string{"windows"}, "Windows"},
+	{[]string{"sql", "enterprise"}, "Linux/UNIX with SQL Server Enterprise"},
+	{[]string{"sql", "standard"}, "Linux/UNIX with SQL Server Standard"},
+	{[]string{"sql", "web"}, "Linux/UNIX with SQL Server Web"},
+	{[]string{"rhel_ha"}, "Red Hat Enterprise Linux with HA"},
+	{[]string{"rhel"}, "Red Hat Enterprise Linux"},
+	{[]string{"suse"}, "SUSE Linux"},
+	{[]string{"sles"}, "SUSE Linux"},
+	{[]string{"ubuntu-pro"}, "Ubuntu Pro"},
+}
+
+// inferPlatformDetails guesses platform details from an image name or name
+// filter, e.g. Windows_Server-2022-English-Full-SQL_2022_Standard-*
+func inferPlatformDetails(name string) string {
+	lower := strings.ToLower(name)
+	for _, rule := range amiPlatformRules {
+		all := true
+		for _, f := range rule.fragments {
+			if !strings.Contains(lower, f) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return rule.platformDetails
+		}
+	}
+	return "Linux/UNIX"
+}
+
+// platformFromDetails converts EC2 platform details into pricing catalog
+// attribute values
+func platformFromDetails(details, source string) *types.Platform {
+	p := &types.Platform{
+		OperatingSystem: "Linux",
+		PreInstalledSw:  "NA",
+		LicenseModel:    "No License required",
+		PlatformDetails: details,
+		Source:          source,
+	}
+
+	switch {
+	case strings.HasPrefix(details, "Windows"):
+		p.OperatingSystem = "Windows"
+	case strings.HasPrefix(details, "Red Hat Enterprise Linux with HA"):
+		p.OperatingSystem = "Red Hat Enterprise Linux with HA"
+	case strings.HasPrefix(details, "Red Hat"):
+		p.OperatingSystem = "RHEL"
+	case strings.HasPrefix(details, "SUSE"):
+		p.OperatingSystem = "SUSE"
+	case strings.HasPrefix(details, "Ubuntu Pro"):
+		p.OperatingSystem = "Ubuntu Pro"
+	}
+
+	switch {
+	case strings.Contains(details, "SQL Server Enterprise"):
+		p.PreInstalledSw = "SQL Ent"
+	case strings.Contains(details, "SQL Server Standard"):
+		p.PreInstalledSw = "SQL Std"
+	case strings.Contains(details, "SQL Server Web"):
+		p.PreInstalledSw = "SQL Web"
+	}
+
+	if strings.Contains(details, "BYOL") {
+		p.LicenseModel = "Bring your own license"
+	}
+	return p
+}
+
+// dataSourcePlatform returns the platform of the image an aws_ami data
+// source resolves to, inferring it from the name filter or name_regex if
+// the image is not known
+func dataSourcePlatform(ds types.TerraformResource, attrs cty.Value, address string) *types.Platform {
+	if attrs.IsKnown() && attrs.Type().IsObjectType() && attrs.Type().HasAttribute("platform_details") {
+		if details := attrs.GetAttr("platform_details"); details.IsKnown() && !details.IsNull() && details.AsString() != "" {
+			return platformFromDetails(details.AsString(), address)
+		}
+	}
+
+	var names []string
+	names = append(names, amiFilters(ds.Config)["name"]...)
+	if pattern, ok := ds.Config["name_regex"].(string); ok {
+		names = append(names, pattern)
+	}
+	for _, details := range amiFilters(ds.Config)["platform-details"] {
+		if !strings.ContainsAny(details, "*?") {
+			return platformFromDetails(details, address)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return platformFromDetails(inferPlatformDetails(strings.Join(names, " ")), address)
+}
+
+// imageArgs are the resource arguments that select a machine image
+var imageArgs = []string{"ami", "image_id"}
+
+// resourcePlatform determines the platform of a resource's machine image,
+// from the aws_ami data source its image argument refers to or from the
+// known images
+func (l *Loader) resourcePlatform(body *configBody, config map[string]interface{}) *types.Platform {
+	for _, arg := range imageArgs {
+		if attr, ok := body.Attributes[arg]; ok {
+			for _, traversal := range attr.Expr.Variables() {
+				if p, ok := l.platforms[dataAddress(traversal)]; ok {
+					return p
+				}
+			}
+		}
+
+		id, ok := config[arg].(string)
+		if !ok || l.metadata == nil {
+			continue
+		}
+		for _, img := range l.metadata.Images {
+			if img.ID == id {
+				return platformFromDetails(img.PlatformDetails, id)
+			}
+		}
+	}
+	return nil
+}
+
+// dataAddress returns the data.type.name address a traversal refers to, or
+// an empty string for any other reference
+func dataAddress(traversal hcl.Traversal) string {
+	if len(traversal) < 3 || traversal.RootName() != "data" {
+		return ""
+	}
+	dataType, ok := traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return ""
+	}
+	name, ok := traversal[2].(hcl.TraverseAttr)
+	if !ok {
+		return ""
+	}
+	return "data." + dataType.Name + "." + name.Name
+}
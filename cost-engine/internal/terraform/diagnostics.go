@@ -0,0 +1,80 @@
+package terraform
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// addDiagnostic records an HCL diagnostic on the plan, attributed to the
+// address of the block it came from, if any
+func (l *Loader) addDiagnostic(plan *types.TerraformPlan, diag *hcl.Diagnostic, address string) {
+	severity := types.SeverityError
+	if diag.Severity == hcl.DiagWarning {
+		severity = types.SeverityWarning
+	}
+
+	d := types.Diagnostic{
+		Severity: severity,
+		Summary:  diag.Summary,
+		Detail:   diag.Detail,
+		Address:  address,
+	}
+	if diag.Subject != nil {
+		d.Range = l.sourceRange(*diag.Subject)
+	}
+
+	plan.Diagnostics = append(plan.Diagnostics, d)
+}
+
+// addDiagnostics records evaluation diagnostics on the plan. References to
+// resources and data sources cannot be resolved before apply; their values
+// are treated as unknown, so those errors are not reported.
+func (l *Loader) addDiagnostics(plan *types.TerraformPlan, diags hcl.Diagnostics, address string) {
+	for _, diag := range diags {
+		if isResourceReference(diag) {
+			continue
+		}
+		l.addDiagnostic(plan, diag, address)
+	}
+}
+
+// isResourceReference reports whether a diagnostic is an unknown variable
+// error for a reference to a resource (aws_vpc.main.id), data source or self,
+// or an unsupported attribute error for a partly resolved data source
+func isResourceReference(diag *hcl.Diagnostic) bool {
+	if diag.Summary != "Unknown variable" && diag.Summary != "Unsupported attribute" {
+		return false
+	}
+	expr, ok := diag.Expression.(*hclsyntax.ScopeTraversalExpr)
+	if !ok {
+		return false
+	}
+	root := expr.Traversal.RootName()
+	if diag.Summary == "Unsupported attribute" {
+		// Resolved data sources only carry the attributes we could infer
+		return root == "data"
+	}
+	return root == "data" || root == "self" || strings.Contains(root, "_")
+}
+
+// sourceRange converts an HCL range to a source range with the filename
+// relative to the configuration root
+func (l *Loader) sourceRange(rng hcl.Range) *types.SourceRange {
+	filename := rng.Filename
+	if rel, err := filepath.Rel(l.rootDir, filename); err == nil {
+		filename = filepath.ToSlash(rel)
+	}
+
+	return &types.SourceRange{
+		Filename:    filename,
+		StartLine:   rng.Start.Line,
+		StartColumn: rng.Start.Column,
+		EndLine:     rng.End.Line,
+		EndColumn:   rng.End.Column,
+	}
+}
@@ -0,0 +1,1037 @@
+package terraform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+	"github.com/zclconf/go-cty/cty/gocty"
+	"golang.org/x/text/encoding/ianaindex"
+	"gopkg.in/yaml.v3"
+)
+
+// functions returns the Terraform built-in functions available to
+// expressions in a module. Functions that read files resolve paths against
+// moduleDir and may not escape rootDir. Non-deterministic functions such as
+// timestamp() and uuid() return unknown values so estimates stay reproducible.
+func functions(rootDir, moduleDir string) map[string]function.Function {
+	funcs := map[string]function.Function{
+		// Numeric
+		"abs":      stdlib.AbsoluteFunc,
+		"ceil":     stdlib.CeilFunc,
+		"floor":    stdlib.FloorFunc,
+		"log":      stdlib.LogFunc,
+		"max":      stdlib.MaxFunc,
+		"min":      stdlib.MinFunc,
+		"parseint": stdlib.ParseIntFunc,
+		"pow":      stdlib.PowFunc,
+		"signum":   stdlib.SignumFunc,
+		"sum":      sumFunc,
+
+		// String
+		"chomp":       stdlib.ChompFunc,
+		"endswith":    endsWithFunc,
+		"format":      stdlib.FormatFunc,
+		"formatlist":  stdlib.FormatListFunc,
+		"indent":      stdlib.IndentFunc,
+		"join":        stdlib.JoinFunc,
+		"lower":       stdlib.LowerFunc,
+		"regex":       stdlib.RegexFunc,
+		"regexall":    stdlib.RegexAllFunc,
+		"replace":     replaceFunc,
+		"split":       stdlib.SplitFunc,
+		"startswith":  startsWithFunc,
+		"strcontains": strContainsFunc,
+		"strrev":      stdlib.ReverseFunc,
+		"substr":      stdlib.SubstrFunc,
+		"title":       stdlib.TitleFunc,
+		"trim":        stdlib.TrimFunc,
+		"trimprefix":  stdlib.TrimPrefixFunc,
+		"trimspace":   stdlib.TrimSpaceFunc,
+		"trimsuffix":  stdlib.TrimSuffixFunc,
+		"upper":       stdlib.UpperFunc,
+		"urlencode":   transformFunc(url.QueryEscape),
+
+		// Collection
+		"alltrue":         allTrueFunc,
+		"anytrue":         anyTrueFunc,
+		"chunklist":       stdlib.ChunklistFunc,
+		"coalesce":        stdlib.CoalesceFunc,
+		"coalescelist":    stdlib.CoalesceListFunc,
+		"compact":         stdlib.CompactFunc,
+		"concat":          stdlib.ConcatFunc,
+		"contains":        stdlib.ContainsFunc,
+		"distinct":        stdlib.DistinctFunc,
+		"element":         stdlib.ElementFunc,
+		"flatten":         stdlib.FlattenFunc,
+		"index":           indexFunc,
+		"keys":            stdlib.KeysFunc,
+		"length":          lengthFunc,
+		"lookup":          lookupFunc,
+		"matchkeys":       matchKeysFunc,
+		"merge":           stdlib.MergeFunc,
+		"one":             oneFunc,
+		"range":           stdlib.RangeFunc,
+		"reverse":         stdlib.ReverseListFunc,
+		"setintersection": stdlib.SetIntersectionFunc,
+		"setproduct":      stdlib.SetProductFunc,
+		"setsubtract":     stdlib.SetSubtractFunc,
+		"setunion":        stdlib.SetUnionFunc,
+		"slice":           stdlib.SliceFunc,
+		"sort":            stdlib.SortFunc,
+		"transpose":       transposeFunc,
+		"values":          stdlib.ValuesFunc,
+		"zipmap":          stdlib.ZipmapFunc,
+
+		// Encoding
+		"base64decode":     base64DecodeFunc,
+		"base64encode":     base64EncodeFunc,
+		"base64gzip":       base64GzipFunc,
+		"csvdecode":        stdlib.CSVDecodeFunc,
+		"jsondecode":       stdlib.JSONDecodeFunc,
+		"jsonencode":       stdlib.JSONEncodeFunc,
+		"textdecodebase64": textDecodeBase64Func,
+		"textencodebase64": textEncodeBase64Func,
+		"yamldecode":       yamlDecodeFunc,
+		"yamlencode":       yamlEncodeFunc,
+
+		// Hash and crypto
+		"base64sha256": transformFunc(func(s string) string { h := sha256.Sum256([]byte(s)); return base64.StdEncoding.EncodeToString(h[:]) }),
+		"md5":          transformFunc(func(s string) string { h := md5.Sum([]byte(s)); return hex.EncodeToString(h[:]) }),
+		"sha1":         transformFunc(func(s string) string { h := sha1.Sum([]byte(s)); return hex.EncodeToString(h[:]) }),
+		"sha256":       transformFunc(func(s string) string { h := sha256.Sum256([]byte(s)); return hex.EncodeToString(h[:]) }),
+		"uuid":         unknownFunc(cty.String),
+		"uuidv5":       uuidV5Func,
+
+		// Date and time
+		"formatdate": stdlib.FormatDateFunc,
+		"timeadd":    stdlib.TimeAddFunc,
+		"timestamp":  unknownFunc(cty.String),
+
+		// IP network
+		"cidrhost":    cidrHostFunc,
+		"cidrnetmask": cidrNetmaskFunc,
+		"cidrsubnet":  cidrSubnetFunc,
+		"cidrsubnets": cidrSubnetsFunc,
+
+		// Type conversion
+		"can":          tryfunc.CanFunc,
+		"nonsensitive": identityFunc,
+		"sensitive":    identityFunc,
+		"tobool":       stdlib.MakeToFunc(cty.Bool),
+		"tolist":       stdlib.MakeToFunc(cty.List(cty.DynamicPseudoType)),
+		"tomap":        stdlib.MakeToFunc(cty.Map(cty.DynamicPseudoType)),
+		"tonumber":     stdlib.MakeToFunc(cty.Number),
+		"toset":        stdlib.MakeToFunc(cty.Set(cty.DynamicPseudoType)),
+		"tostring":     stdlib.MakeToFunc(cty.String),
+		"try":          tryfunc.TryFunc,
+
+		// Filesystem
+		"abspath":    makeAbsPathFunc(rootDir),
+		"basename":   transformFunc(filepath.Base),
+		"dirname":    transformFunc(filepath.Dir),
+		"file":       makeFileFunc(rootDir, moduleDir),
+		"filebase64": makeFileBase64Func(rootDir, moduleDir),
+		"fileexists": makeFileExistsFunc(rootDir, moduleDir),
+		"fileset":    makeFileSetFunc(rootDir, moduleDir),
+	}
+
+	// Templates see every function but templatefile itself, as in Terraform
+	templateFuncs := make(map[string]function.Function, len(funcs))
+	for name, fn := range funcs {
+		templateFuncs[name] = fn
+	}
+	funcs["templatefile"] = makeTemplateFileFunc(rootDir, moduleDir, templateFuncs)
+	return funcs
+}
+
+// lookupFunc is Terraform's lookup, where the default argument is optional
+var lookupFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "inputMap", Type: cty.DynamicPseudoType},
+		{Name: "key", Type: cty.String},
+	},
+	VarParam: &function.Parameter{Name: "default", Type: cty.DynamicPseudoType, AllowNull: true},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		if len(args) > 3 {
+			return cty.NilType, fmt.Errorf("lookup() takes at most three arguments")
+		}
+		if len(args) == 3 {
+			return stdlib.LookupFunc.ReturnType([]cty.Type{args[0].Type(), args[1].Type(), args[2].Type()})
+		}
+		ty := args[0].Type()
+		switch {
+		case ty.IsMapType():
+			return ty.ElementType(), nil
+		default:
+			return cty.DynamicPseudoType, nil
+		}
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if len(args) == 3 {
+			return stdlib.LookupFunc.Call(args)
+		}
+		if !args[0].IsWhollyKnown() || !args[1].IsKnown() {
+			return cty.UnknownVal(retType), nil
+		}
+		key := args[1].AsString()
+		ty := args[0].Type()
+		switch {
+		case ty.IsObjectType():
+			if ty.HasAttribute(key) {
+				return args[0].GetAttr(key), nil
+			}
+		case ty.IsMapType():
+			if args[0].HasIndex(args[1]).True() {
+				return args[0].Index(args[1]), nil
+			}
+		default:
+			return cty.NilVal, fmt.Errorf("lookup() requires a map as the first argument")
+		}
+		return cty.NilVal, fmt.Errorf("lookup failed to find key %q", key)
+	},
+})
+
+// lengthFunc is Terraform's length, which also accepts strings and objects
+var lengthFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "value", Type: cty.DynamicPseudoType, AllowDynamicType: true, AllowUnknown: true},
+	},
+	Type: function.StaticReturnType(cty.Number),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		val := args[0]
+		ty := val.Type()
+		switch {
+		case ty == cty.DynamicPseudoType:
+			return cty.UnknownVal(cty.Number), nil
+		case ty == cty.String:
+			if !val.IsKnown() {
+				return cty.UnknownVal(cty.Number), nil
+			}
+			return stdlib.Strlen(val)
+		case ty.IsObjectType():
+			return cty.NumberIntVal(int64(len(ty.AttributeTypes()))), nil
+		case ty.IsListType() || ty.IsSetType() || ty.IsMapType() || ty.IsTupleType():
+			return val.Length(), nil
+		default:
+			return cty.UnknownVal(cty.Number), fmt.Errorf("argument must be a string, a collection type, or a structural type")
+		}
+	},
+})
+
+// indexFunc is Terraform's index, returning the position of a value in a list
+var indexFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "list", Type: cty.DynamicPseudoType},
+		{Name: "value", Type: cty.DynamicPseudoType},
+	},
+	Type: function.StaticReturnType(cty.Number),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if !args[0].IsWhollyKnown() || !args[1].IsKnown() {
+			return cty.UnknownVal(cty.Number), nil
+		}
+		ty := args[0].Type()
+		if !ty.IsListType() && !ty.IsTupleType() {
+			return cty.NilVal, fmt.Errorf("argument must be a list or tuple")
+		}
+		for it := args[0].ElementIterator(); it.Next(); {
+			i, v := it.Element()
+			eq, err := stdlib.Equal(v, args[1])
+			if err != nil {
+				return cty.NilVal, err
+			}
+			if eq.IsKnown() && eq.True() {
+				return i, nil
+			}
+		}
+		return cty.NilVal, fmt.Errorf("item not found")
+	},
+})
+
+// oneFunc returns the single element of a collection, or null when empty
+var oneFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "list", Type: cty.DynamicPseudoType},
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		ty := args[0].Type()
+		switch {
+		case ty.IsListType() || ty.IsSetType():
+			return ty.ElementType(), nil
+		case ty.IsTupleType():
+			if elems := ty.TupleElementTypes(); len(elems) == 1 {
+				return elems[0], nil
+			}
+			return cty.DynamicPseudoType, nil
+		default:
+			return cty.NilType, fmt.Errorf("must be a list, set, or tuple value with either zero or one elements")
+		}
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if !args[0].IsKnown() {
+			return cty.UnknownVal(retType), nil
+		}
+		switch args[0].LengthInt() {
+		case 0:
+			return cty.NullVal(retType), nil
+		case 1:
+			it := args[0].ElementIterator()
+			it.Next()
+			_, v := it.Element()
+			return v, nil
+		default:
+			return cty.NilVal, fmt.Errorf("must be a list, set, or tuple value with either zero or one elements")
+		}
+	},
+})
+
+// sumFunc adds up a collection of numbers
+var sumFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "list", Type: cty.DynamicPseudoType},
+	},
+	Type: function.StaticReturnType(cty.Number),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if !args[0].IsWhollyKnown() {
+			return cty.UnknownVal(cty.Number), nil
+		}
+		if !args[0].CanIterateElements() || args[0].LengthInt() == 0 {
+			return cty.NilVal, fmt.Errorf("cannot sum an empty or non-collection value")
+		}
+		total := cty.Zero
+		for it := args[0].ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			n, err := convert.Convert(v, cty.Number)
+			if err != nil || n.IsNull() {
+				return cty.NilVal, fmt.Errorf("sum() requires a collection of numbers")
+			}
+			total = total.Add(n)
+		}
+		return total, nil
+	},
+})
+
+// allTrueFunc and anyTrueFunc reduce a collection of booleans
+var allTrueFunc = boolReduceFunc(true)
+var anyTrueFunc = boolReduceFunc(false)
+
+// boolReduceFunc returns alltrue (all=true) or anytrue (all=false)
+func boolReduceFunc(all bool) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "list", Type: cty.List(cty.Bool)},
+		},
+		Type: function.StaticReturnType(cty.Bool),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			if !args[0].IsWhollyKnown() {
+				return cty.UnknownVal(cty.Bool), nil
+			}
+			for it := args[0].ElementIterator(); it.Next(); {
+				_, v := it.Element()
+				if v.IsNull() {
+					continue
+				}
+				if v.True() != all {
+					return cty.BoolVal(!all), nil
+				}
+			}
+			return cty.BoolVal(all), nil
+		},
+	})
+}
+
+// replaceFunc is Terraform's replace, which treats a substring wrapped in
+// forward slashes as a regular expression
+var replaceFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "str", Type: cty.String},
+		{Name: "substr", Type: cty.String},
+		{Name: "replace", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		substr := args[1].AsString()
+		if len(substr) > 1 && strings.HasPrefix(substr, "/") && strings.HasSuffix(substr, "/") {
+			pattern := cty.StringVal(substr[1 : len(substr)-1])
+			return stdlib.RegexReplace(args[0], pattern, args[2])
+		}
+		return stdlib.Replace(args[0], args[1], args[2])
+	},
+})
+
+// startsWithFunc, endsWithFunc and strContainsFunc test substrings
+var startsWithFunc = stringPredicateFunc(strings.HasPrefix)
+var endsWithFunc = stringPredicateFunc(strings.HasSuffix)
+var strContainsFunc = stringPredicateFunc(strings.Contains)
+
+// stringPredicateFunc wraps a two-string predicate as a function
+func stringPredicateFunc(fn func(s, sub string) bool) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "str", Type: cty.String},
+			{Name: "substr", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.Bool),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return cty.BoolVal(fn(args[0].AsString(), args[1].AsString())), nil
+		},
+	})
+}
+
+// base64EncodeFunc encodes a string as base64
+var base64EncodeFunc = transformFunc(func(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+})
+
+// base64DecodeFunc decodes a base64 string
+var base64DecodeFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "str", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		decoded, err := base64.StdEncoding.DecodeString(args[0].AsString())
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("failed to decode base64 data: %w", err)
+		}
+		return cty.StringVal(string(decoded)), nil
+	},
+})
+
+// base64GzipFunc compresses a string with gzip and encodes it as base64
+var base64GzipFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "str", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write([]byte(args[0].AsString())); err != nil {
+			return cty.NilVal, fmt.Errorf("failed to compress: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return cty.NilVal, fmt.Errorf("failed to compress: %w", err)
+		}
+		return cty.StringVal(base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+	},
+})
+
+// textEncodeBase64Func encodes a string in a character encoding, named as
+// in the IANA registry, and then as base64
+var textEncodeBase64Func = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "string", Type: cty.String},
+		{Name: "encoding", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		enc, err := ianaindex.IANA.Encoding(args[1].AsString())
+		if err != nil || enc == nil {
+			return cty.NilVal, function.NewArgErrorf(1, "%q is not a supported IANA encoding name or alias", args[1].AsString())
+		}
+		encoded, err := enc.NewEncoder().Bytes([]byte(args[0].AsString()))
+		if err != nil {
+			return cty.NilVal, function.NewArgErrorf(0, "the given string contains characters that cannot be represented in %s", args[1].AsString())
+		}
+		return cty.StringVal(base64.StdEncoding.EncodeToString(encoded)), nil
+	},
+})
+
+// textDecodeBase64Func decodes base64 text in a character encoding, named
+// as in the IANA registry
+var textDecodeBase64Func = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "source", Type: cty.String},
+		{Name: "encoding", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		enc, err := ianaindex.IANA.Encoding(args[1].AsString())
+		if err != nil || enc == nil {
+			return cty.NilVal, function.NewArgErrorf(1, "%q is not a supported IANA encoding name or alias", args[1].AsString())
+		}
+		src, err := base64.StdEncoding.DecodeString(args[0].AsString())
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("failed to decode base64 data: %w", err)
+		}
+		decoded, err := enc.NewDecoder().Bytes(src)
+		if err != nil {
+			return cty.NilVal, function.NewArgErrorf(0, "the given value is not valid %s", args[1].AsString())
+		}
+		return cty.StringVal(string(decoded)), nil
+	},
+})
+
+// yamlDecodeFunc parses a YAML document into the value JSON would decode it as
+var yamlDecodeFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "src", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.DynamicPseudoType),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		var doc interface{}
+		if err := yaml.Unmarshal([]byte(args[0].AsString()), &doc); err != nil {
+			return cty.NilVal, fmt.Errorf("failed to decode YAML: %w", err)
+		}
+		src, err := json.Marshal(doc)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("failed to decode YAML: %w", err)
+		}
+		return stdlib.JSONDecode(cty.StringVal(string(src)))
+	},
+})
+
+// yamlEncodeFunc encodes a value as a YAML document with sorted keys
+var yamlEncodeFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "value", Type: cty.DynamicPseudoType, AllowNull: true},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if !args[0].IsWhollyKnown() {
+			return cty.UnknownVal(cty.String), nil
+		}
+		src, err := stdlib.JSONEncode(args[0])
+		if err != nil {
+			return cty.NilVal, err
+		}
+		var doc interface{}
+		if err := json.Unmarshal([]byte(src.AsString()), &doc); err != nil {
+			return cty.NilVal, err
+		}
+
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return cty.NilVal, err
+		}
+		if err := enc.Close(); err != nil {
+			return cty.NilVal, err
+		}
+		return cty.StringVal(buf.String()), nil
+	},
+})
+
+// transposeFunc swaps the keys and values of a map of lists of strings
+var transposeFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "values", Type: cty.Map(cty.List(cty.String))},
+	},
+	Type: function.StaticReturnType(cty.Map(cty.List(cty.String))),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if !args[0].IsWhollyKnown() {
+			return cty.UnknownVal(retType), nil
+		}
+
+		transposed := make(map[string][]cty.Value)
+		for it := args[0].ElementIterator(); it.Next(); {
+			key, list := it.Element()
+			for lit := list.ElementIterator(); lit.Next(); {
+				_, val := lit.Element()
+				if val.IsNull() {
+					return cty.NilVal, fmt.Errorf("transpose requires a map of lists of strings, found null in %q", key.AsString())
+				}
+				transposed[val.AsString()] = append(transposed[val.AsString()], key)
+			}
+		}
+		if len(transposed) == 0 {
+			return cty.MapValEmpty(cty.List(cty.String)), nil
+		}
+
+		result := make(map[string]cty.Value, len(transposed))
+		for key, vals := range transposed {
+			result[key] = cty.ListVal(vals)
+		}
+		return cty.MapVal(result), nil
+	},
+})
+
+// matchKeysFunc returns the elements of values whose corresponding element
+// in keys is in searchset
+var matchKeysFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "values", Type: cty.List(cty.DynamicPseudoType)},
+		{Name: "keys", Type: cty.List(cty.DynamicPseudoType)},
+		{Name: "searchset", Type: cty.List(cty.DynamicPseudoType)},
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		return args[0].Type(), nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if args[0].LengthInt() != args[1].LengthInt() {
+			return cty.NilVal, function.NewArgErrorf(1, "length of keys and values should be equal")
+		}
+		if !args[1].IsWhollyKnown() || !args[2].IsWhollyKnown() {
+			return cty.UnknownVal(retType), nil
+		}
+
+		values := args[0].AsValueSlice()
+		var matched []cty.Value
+		for i, key := range args[1].AsValueSlice() {
+			for _, search := range args[2].AsValueSlice() {
+				// Keys of a different type than the search set never match
+				if eq := key.Equals(search); eq.IsKnown() && eq.True() {
+					matched = append(matched, values[i])
+					break
+				}
+			}
+		}
+		if len(matched) == 0 {
+			return cty.ListValEmpty(retType.ElementType()), nil
+		}
+		return cty.ListVal(matched), nil
+	},
+})
+
+// uuidNamespaces are the predefined name spaces of uuidv5
+var uuidNamespaces = map[string]string{
+	"dns":  "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	"url":  "6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+	"oid":  "6ba7b812-9dad-11d1-80b4-00c04fd430c8",
+	"x500": "6ba7b814-9dad-11d1-80b4-00c04fd430c8",
+}
+
+// uuidV5Func generates a name-based (SHA-1) UUID, which unlike uuid() is
+// deterministic
+var uuidV5Func = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "namespace", Type: cty.String},
+		{Name: "name", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		namespace := args[0].AsString()
+		if ns, ok := uuidNamespaces[namespace]; ok {
+			namespace = ns
+		}
+		ns, err := hex.DecodeString(strings.ReplaceAll(namespace, "-", ""))
+		if err != nil || len(ns) != 16 {
+			return cty.NilVal, function.NewArgErrorf(0, "must be one of dns, url, oid, x500 or a valid UUID, got %q", args[0].AsString())
+		}
+
+		h := sha1.Sum(append(ns, args[1].AsString()...))
+		u := h[:16]
+		u[6] = u[6]&0x0f | 0x50 // Version 5
+		u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+		return cty.StringVal(fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])), nil
+	},
+})
+
+// transformFunc wraps a string-to-string transform, such as an encoding or hash,
+// as a function
+func transformFunc(fn func(string) string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "str", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return cty.StringVal(fn(args[0].AsString())), nil
+		},
+	})
+}
+
+// unknownFunc returns a function whose result is always unknown
+func unknownFunc(ty cty.Type) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{},
+		Type:   function.StaticReturnType(ty),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return cty.UnknownVal(ty), nil
+		},
+	})
+}
+
+// identityFunc returns its argument unchanged
+var identityFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "value", Type: cty.DynamicPseudoType, AllowNull: true, AllowUnknown: true, AllowDynamicType: true},
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		return args[0].Type(), nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		return args[0], nil
+	},
+})
+
+// makeFileFunc returns file(), reading paths relative to the module directory
+func makeFileFunc(rootDir, moduleDir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "path", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path, err := resolveFilePath(rootDir, moduleDir, args[0].AsString())
+			if err != nil {
+				return cty.NilVal, err
+			}
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return cty.NilVal, fmt.Errorf("failed to read %s", args[0].AsString())
+			}
+			return cty.StringVal(string(src)), nil
+		},
+	})
+}
+
+// makeFileExistsFunc returns fileexists(), resolving paths like file()
+func makeFileExistsFunc(rootDir, moduleDir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "path", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.Bool),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path, err := resolveFilePath(rootDir, moduleDir, args[0].AsString())
+			if err != nil {
+				return cty.NilVal, err
+			}
+			info, err := os.Stat(path)
+			return cty.BoolVal(err == nil && info.Mode().IsRegular()), nil
+		},
+	})
+}
+
+// makeFileBase64Func returns filebase64(), resolving paths like file()
+func makeFileBase64Func(rootDir, moduleDir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "path", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path, err := resolveFilePath(rootDir, moduleDir, args[0].AsString())
+			if err != nil {
+				return cty.NilVal, err
+			}
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return cty.NilVal, fmt.Errorf("failed to read %s", args[0].AsString())
+			}
+			return cty.StringVal(base64.StdEncoding.EncodeToString(src)), nil
+		},
+	})
+}
+
+// makeFileSetFunc returns fileset(), listing the regular files below a
+// directory whose relative path matches a pattern; ** matches any number of
+// directories
+func makeFileSetFunc(rootDir, moduleDir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "path", Type: cty.String},
+			{Name: "pattern", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.Set(cty.String)),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			base, err := resolveFilePath(rootDir, moduleDir, args[0].AsString())
+			if err != nil {
+				return cty.NilVal, err
+			}
+			pattern := args[1].AsString()
+			if _, err := path.Match(pattern, ""); err != nil {
+				return cty.NilVal, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+
+			var matches []cty.Value
+			err = filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+				if err != nil || !d.Type().IsRegular() {
+					return nil
+				}
+				rel, _ := filepath.Rel(base, p)
+				rel = filepath.ToSlash(rel)
+				if matchPathPattern(strings.Split(pattern, "/"), strings.Split(rel, "/")) {
+					matches = append(matches, cty.StringVal(rel))
+				}
+				return nil
+			})
+			if err != nil {
+				return cty.NilVal, err
+			}
+			if len(matches) == 0 {
+				return cty.SetValEmpty(cty.String), nil
+			}
+			return cty.SetVal(matches), nil
+		},
+	})
+}
+
+// matchPathPattern matches path segments against pattern segments, where a
+// ** segment matches zero or more segments
+func matchPathPattern(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchPathPattern(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchPathPattern(pattern[1:], segments[1:])
+}
+
+// makeAbsPathFunc returns abspath(). Terraform resolves relative paths
+// against the working directory, which is the root module.
+func makeAbsPathFunc(rootDir string) function.Function {
+	return transformFunc(func(p string) string {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(rootDir, p)
+		}
+		return filepath.ToSlash(filepath.Clean(p))
+	})
+}
+
+// makeTemplateFileFunc returns templatefile(), rendering a template file
+// with the given variables and functions
+func makeTemplateFileFunc(rootDir, moduleDir string, funcs map[string]function.Function) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "path", Type: cty.String},
+			{Name: "vars", Type: cty.DynamicPseudoType},
+		},
+		Type: function.StaticReturnType(cty.DynamicPseudoType),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			vars := args[1]
+			if ty := vars.Type(); !ty.IsObjectType() && !ty.IsMapType() {
+				return cty.NilVal, function.NewArgErrorf(1, "invalid vars value: must be a map")
+			}
+
+			file, err := resolveFilePath(rootDir, moduleDir, args[0].AsString())
+			if err != nil {
+				return cty.NilVal, err
+			}
+			src, err := os.ReadFile(file)
+			if err != nil {
+				return cty.NilVal, fmt.Errorf("failed to read %s", args[0].AsString())
+			}
+			expr, diags := hclsyntax.ParseTemplate(src, args[0].AsString(), hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				return cty.NilVal, diags
+			}
+
+			ctx := &hcl.EvalContext{
+				Variables: vars.AsValueMap(),
+				Functions: funcs,
+			}
+			val, diags := expr.Value(ctx)
+			if diags.HasErrors() {
+				return cty.NilVal, diags
+			}
+			return val, nil
+		},
+	})
+}
+
+// resolveFilePath resolves a file function path, refusing paths outside rootDir
+func resolveFilePath(rootDir, moduleDir, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(moduleDir, path)
+	}
+	path = filepath.Clean(path)
+	if path != rootDir && !strings.HasPrefix(path, rootDir+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the configuration", path)
+	}
+	return path, nil
+}
+
+// cidrHostFunc calculates a full host IP address within a network prefix
+var cidrHostFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "prefix", Type: cty.String},
+		{Name: "hostnum", Type: cty.Number},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		network, err := parseCIDR(args[0].AsString())
+		if err != nil {
+			return cty.NilVal, err
+		}
+		hostnum, _ := args[1].AsBigFloat().Int(nil)
+
+		ones, bits := network.Mask.Size()
+		hostSpace := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+		if hostnum.Sign() < 0 {
+			hostnum.Add(hostnum, hostSpace)
+		}
+		if hostnum.Sign() < 0 || hostnum.Cmp(hostSpace) >= 0 {
+			return cty.NilVal, fmt.Errorf("prefix of %d does not accommodate a host numbered %s", ones, args[1].AsBigFloat().String())
+		}
+
+		ip := new(big.Int).Or(ipToInt(network.IP), hostnum)
+		return cty.StringVal(intToIP(ip, bits).String()), nil
+	},
+})
+
+// cidrNetmaskFunc converts an IPv4 prefix into a dotted netmask
+var cidrNetmaskFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "prefix", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		network, err := parseCIDR(args[0].AsString())
+		if err != nil {
+			return cty.NilVal, err
+		}
+		if len(network.Mask) != net.IPv4len {
+			return cty.NilVal, fmt.Errorf("only IPv4 networks have a netmask")
+		}
+		return cty.StringVal(net.IP(network.Mask).String()), nil
+	},
+})
+
+// cidrSubnetFunc calculates a subnet address within a network prefix
+var cidrSubnetFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "prefix", Type: cty.String},
+		{Name: "newbits", Type: cty.Number},
+		{Name: "netnum", Type: cty.Number},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		network, err := parseCIDR(args[0].AsString())
+		if err != nil {
+			return cty.NilVal, err
+		}
+		var newbits int
+		if err := gocty.FromCtyValue(args[1], &newbits); err != nil {
+			return cty.NilVal, fmt.Errorf("invalid newbits: %w", err)
+		}
+		netnum, _ := args[2].AsBigFloat().Int(nil)
+
+		subnet, err := cidrSubnet(network, newbits, netnum)
+		if err != nil {
+			return cty.NilVal, err
+		}
+		return cty.StringVal(subnet.String()), nil
+	},
+})
+
+// cidrSubnetsFunc allocates consecutive subnets of the given sizes
+var cidrSubnetsFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "prefix", Type: cty.String},
+	},
+	VarParam: &function.Parameter{Name: "newbits", Type: cty.Number},
+	Type:     function.StaticReturnType(cty.List(cty.String)),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		network, err := parseCIDR(args[0].AsString())
+		if err != nil {
+			return cty.NilVal, err
+		}
+		if len(args) == 1 {
+			return cty.ListValEmpty(cty.String), nil
+		}
+
+		ones, bits := network.Mask.Size()
+		base := ipToInt(network.IP)
+		limit := new(big.Int).Add(base, new(big.Int).Lsh(big.NewInt(1), uint(bits-ones)))
+		next := new(big.Int).Set(base)
+
+		subnets := make([]cty.Value, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			var newbits int
+			if err := gocty.FromCtyValue(arg, &newbits); err != nil {
+				return cty.NilVal, fmt.Errorf("invalid newbits: %w", err)
+			}
+			length := ones + newbits
+			if newbits < 0 || length > bits {
+				return cty.NilVal, fmt.Errorf("not enough remaining address space for a subnet with a prefix of %d bits", length)
+			}
+
+			// Align the next free address to the subnet size
+			size := new(big.Int).Lsh(big.NewInt(1), uint(bits-length))
+			if rem := new(big.Int).Mod(new(big.Int).Sub(next, base), size); rem.Sign() != 0 {
+				next.Add(next, new(big.Int).Sub(size, rem))
+			}
+			end := new(big.Int).Add(next, size)
+			if end.Cmp(limit) > 0 {
+				return cty.NilVal, fmt.Errorf("not enough remaining address space for a subnet with a prefix of %d bits", length)
+			}
+
+			subnet := &net.IPNet{IP: intToIP(next, bits), Mask: net.CIDRMask(length, bits)}
+			subnets = append(subnets, cty.StringVal(subnet.String()))
+			next = end
+		}
+		return cty.ListVal(subnets), nil
+	},
+})
+
+// cidrSubnet returns subnet number netnum of the given size within network
+func cidrSubnet(network *net.IPNet, newbits int, netnum *big.Int) (*net.IPNet, error) {
+	ones, bits := network.Mask.Size()
+	length := ones + newbits
+	if newbits < 0 || length > bits {
+		return nil, fmt.Errorf("insufficient address space to extend prefix of %d by %d", ones, newbits)
+	}
+
+	maxNum := new(big.Int).Lsh(big.NewInt(1), uint(newbits))
+	if netnum.Sign() < 0 || netnum.Cmp(maxNum) >= 0 {
+		return nil, fmt.Errorf("prefix extension of %d does not accommodate a subnet numbered %s", newbits, netnum.String())
+	}
+
+	offset := new(big.Int).Lsh(netnum, uint(bits-length))
+	ip := new(big.Int).Or(ipToInt(network.IP), offset)
+	return &net.IPNet{IP: intToIP(ip, bits), Mask: net.CIDRMask(length, bits)}, nil
+}
+
+// parseCIDR parses a network prefix, normalising IPv4 addresses to 4 bytes
+func parseCIDR(prefix string) (*net.IPNet, error) {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR expression: %w", err)
+	}
+	if ip4 := network.IP.To4(); ip4 != nil {
+		network.IP = ip4
+	}
+	return network, nil
+}
+
+// ipToInt converts an IP address to an integer
+func ipToInt(ip net.IP) *big.Int {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return new(big.Int).SetBytes(ip)
+}
+
+// intToIP converts an integer to an IP address of the given bit length
+func intToIP(n *big.Int, bits int) net.IP {
+	buf := make([]byte, bits/8)
+	n.FillBytes(buf)
+	return net.IP(buf)
+}
@@ -0,0 +1,104 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestFunctions(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"user_data.tftpl":      "#!/bin/sh\necho ${name} %{ for p in ports }${p} %{ endfor }\n",
+		"config/a.yaml":        "a: 1\n",
+		"config/nested/b.yaml": "b: 2\n",
+		"config/c.json":        "{}",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		expr string
+		want cty.Value
+	}{
+		{`yamlencode({b = [1, "x"], a = true})`, cty.StringVal("a: true\nb:\n  - 1\n  - x\n")},
+		{`yamldecode("a: 1\nb: [x, y]\n").b[1]`, cty.StringVal("y")},
+		{`templatefile("user_data.tftpl", { name = "web", ports = [80, 443] })`, cty.StringVal("#!/bin/sh\necho web 80 443 \n")},
+		{`fileset(".", "config/**/*.yaml")`, cty.SetVal([]cty.Value{cty.StringVal("config/a.yaml"), cty.StringVal("config/nested/b.yaml")})},
+		{`fileset("config", "*.txt")`, cty.SetValEmpty(cty.String)},
+		{`filebase64("config/c.json")`, cty.StringVal("e30=")},
+		{`basename("foo/bar/baz.txt")`, cty.StringVal("baz.txt")},
+		{`dirname("foo/bar/baz.txt")`, cty.StringVal("foo/bar")},
+		{`abspath("foo") == "` + filepath.ToSlash(root) + `/foo"`, cty.True},
+		{`urlencode("a b&c=d/e")`, cty.StringVal("a+b%26c%3Dd%2Fe")},
+		{`textencodebase64("Hello", "UTF-16LE")`, cty.StringVal("SABlAGwAbABvAA==")},
+		{`textdecodebase64("SABlAGwAbABvAA==", "UTF-16LE")`, cty.StringVal("Hello")},
+		{`base64decode(base64gzip("")) != ""`, cty.True},
+		{`transpose({a = ["1", "2"], b = ["2", "3"]})`, cty.MapVal(map[string]cty.Value{
+			"1": cty.ListVal([]cty.Value{cty.StringVal("a")}),
+			"2": cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+			"3": cty.ListVal([]cty.Value{cty.StringVal("b")}),
+		})},
+		{`matchkeys(["i-1", "i-2", "i-3"], ["us-west-1a", "us-west-1b", "us-west-1c"], ["us-west-1a", "us-west-1c"])`,
+			cty.ListVal([]cty.Value{cty.StringVal("i-1"), cty.StringVal("i-3")})},
+		{`matchkeys(["a"], ["x"], ["y"])`, cty.ListValEmpty(cty.String)},
+		{`uuidv5("dns", "www.example.com")`, cty.StringVal("2ed6657d-e927-568b-95e1-2665a8aea6a2")},
+		{`uuidv5("6ba7b811-9dad-11d1-80b4-00c04fd430c8", "https://example.com")`, cty.StringVal("4fd35a71-71ef-5a55-a9d9-aa75c889a6d0")},
+	}
+
+	ctx := &hcl.EvalContext{Functions: functions(root, root)}
+	for _, tt := range tests {
+		expr, diags := hclsyntax.ParseExpression([]byte(tt.expr), "test.tf", hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			t.Fatalf("%s: %s", tt.expr, diags.Error())
+		}
+		got, diags := expr.Value(ctx)
+		if diags.HasErrors() {
+			t.Errorf("%s: %s", tt.expr, diags.Error())
+			continue
+		}
+		if !got.RawEquals(tt.want) {
+			t.Errorf("%s = %#v, want %#v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestFunctionsStayInsideRoot(t *testing.T) {
+	root := t.TempDir()
+	ctx := &hcl.EvalContext{Functions: functions(root, root)}
+
+	for _, src := range []string{`filebase64("/etc/hostname")`, `fileset("../", "*")`, `templatefile("../x.tftpl", {})`} {
+		expr, _ := hclsyntax.ParseExpression([]byte(src), "test.tf", hcl.Pos{Line: 1, Column: 1})
+		if _, diags := expr.Value(ctx); !diags.HasErrors() {
+			t.Errorf("%s: expected an error for a path outside the configuration", src)
+		}
+	}
+}
+
+func TestSetValuesInConfig(t *testing.T) {
+	plan := loadFiles(t, map[string]string{
+		"main.tf": `
+resource "aws_instance" "web" {
+  instance_type          = "t3.micro"
+  vpc_security_group_ids = toset(["sg-2", "sg-1", "sg-2"])
+}
+`,
+	})
+
+	got := plan.Resources[0].Config["vpc_security_group_ids"]
+	if want := []interface{}{"sg-1", "sg-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("vpc_security_group_ids = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,224 @@
+package terraform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// graphNode is a local value, provider configuration, module call or data
+// source that other expressions may reference
+type graphNode struct {
+	name     string
+	local    *hcl.Attribute // set for local values
+	provider *configBlock   // set for provider configurations
+	module   *configBlock   // set for module calls
+	data     *configBlock   // set for data sources
+	cyclic   bool           // part of a reference cycle; not evaluated
+}
+
+// address returns the reference address of the node (local.x, provider.x,
+// module.x or data.type.name)
+func (n *graphNode) address() string {
+	switch {
+	case n.local != nil:
+		return "local." + n.name
+	case n.provider != nil:
+		return "provider." + n.name
+	case n.data != nil:
+		return "data." + n.name
+	default:
+		return "module." + n.name
+	}
+}
+
+// orderDependencies returns the locals, providers, module calls and data
+// sources of a module sorted so that every node comes after the nodes it
+// references. Module calls and data sources also depend on every provider,
+// since child modules inherit provider configurations and data sources read
+// their provider's region. Nodes on a reference cycle are flagged and
+// reported as diagnostics.
+func (l *Loader) orderDependencies(cfg *moduleConfig, plan *types.TerraformPlan) []*graphNode {
+	var nodes []*graphNode
+	byAddress := make(map[string]*graphNode)
+
+	for _, block := range cfg.locals {
+		// Sort names so evaluation order is deterministic
+		names := make([]string, 0, len(block.Body.Attributes))
+		for name := range block.Body.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			node := &graphNode{name: name, local: block.Body.Attributes[name]}
+			nodes = append(nodes, node)
+			byAddress[node.address()] = node
+		}
+	}
+	var providers []*graphNode
+	for _, block := range cfg.providers {
+		key := providerKey(block)
+		if key == "" {
+			continue
+		}
+		node := &graphNode{name: key, provider: block}
+		nodes = append(nodes, node)
+		providers = append(providers, node)
+		byAddress[node.address()] = node
+	}
+	for _, block := range cfg.moduleCalls {
+		if len(block.Labels) == 0 {
+			continue
+		}
+		node := &graphNode{name: block.Labels[0], module: block}
+		nodes = append(nodes, node)
+		byAddress[node.address()] = node
+	}
+	for _, block := range cfg.dataSources {
+		if len(block.Labels) < 2 {
+			continue
+		}
+		node := &graphNode{name: block.Labels[0] + "." + block.Labels[1], data: block}
+		nodes = append(nodes, node)
+		byAddress[node.address()] = node
+	}
+
+	// Resolve the references of each node to other nodes
+	deps := make(map[*graphNode][]*graphNode)
+	for _, node := range nodes {
+		var traversals []hcl.Traversal
+		switch {
+		case node.local != nil:
+			traversals = node.local.Expr.Variables()
+		case node.provider != nil:
+			for _, attr := range node.provider.Body.Attributes {
+				traversals = append(traversals, attr.Expr.Variables()...)
+			}
+		case node.data != nil:
+			traversals = bodyVariables(node.data.Body)
+			deps[node] = append(deps[node], providers...)
+		default:
+			for _, attr := range node.module.Body.Attributes {
+				if attr.Name == "providers" {
+					continue
+				}
+				traversals = append(traversals, attr.Expr.Variables()...)
+			}
+			deps[node] = append(deps[node], providers...)
+		}
+
+		for _, traversal := range traversals {
+			if dep, ok := byAddress[referenceAddress(traversal)]; ok {
+				deps[node] = append(deps[node], dep)
+			}
+		}
+	}
+
+	// Depth-first topological sort, visiting nodes in declaration order
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[*graphNode]int)
+	var ordered []*graphNode
+	var stack []*graphNode
+
+	var visit func(node *graphNode)
+	visit = func(node *graphNode) {
+		switch state[node] {
+		case done:
+			return
+		case visiting:
+			// Everything on the stack from the first occurrence forms the cycle
+			start := 0
+			for i, n := range stack {
+				if n == node {
+					start = i
+					break
+				}
+			}
+			cycle := stack[start:]
+			path := make([]string, 0, len(cycle)+1)
+			for _, n := range cycle {
+				n.cyclic = true
+				path = append(path, n.address())
+			}
+			path = append(path, node.address())
+			l.addDiagnostic(plan, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Cycle in references",
+				Detail:   fmt.Sprintf("The values %s refer to each other and cannot be evaluated.", strings.Join(path, " -> ")),
+				Subject:  node.rangePtr(),
+			}, "")
+			return
+		}
+
+		state[node] = visiting
+		stack = append(stack, node)
+		for _, dep := range deps[node] {
+			visit(dep)
+		}
+		stack = stack[:len(stack)-1]
+		state[node] = done
+		ordered = append(ordered, node)
+	}
+
+	for _, node := range nodes {
+		visit(node)
+	}
+
+	return ordered
+}
+
+// rangePtr returns the source range of the node's definition
+func (n *graphNode) rangePtr() *hcl.Range {
+	switch {
+	case n.local != nil:
+		return n.local.Range.Ptr()
+	case n.provider != nil:
+		return n.provider.DefRange.Ptr()
+	case n.data != nil:
+		return n.data.DefRange.Ptr()
+	default:
+		return n.module.DefRange.Ptr()
+	}
+}
+
+// bodyVariables returns the references made by a body's attributes and
+// nested blocks
+func bodyVariables(body *configBody) []hcl.Traversal {
+	var traversals []hcl.Traversal
+	for _, attr := range body.Attributes {
+		traversals = append(traversals, attr.Expr.Variables()...)
+	}
+	for _, block := range body.Blocks {
+		traversals = append(traversals, bodyVariables(block.Body)...)
+	}
+	return traversals
+}
+
+// referenceAddress returns the local.x, module.x or data.type.name address a
+// traversal refers to, or an empty string for any other reference
+func referenceAddress(traversal hcl.Traversal) string {
+	if traversal.RootName() == "data" {
+		return dataAddress(traversal)
+	}
+	if len(traversal) < 2 {
+		return ""
+	}
+	root := traversal.RootName()
+	if root != "local" && root != "module" {
+		return ""
+	}
+	attr, ok := traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return ""
+	}
+	return root + "." + attr.Name
+}
@@ -0,0 +1,123 @@
+package terraform
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// addRequiredInput records a variable that has neither a value nor a
+// default. It is bound to an unknown value so dependent attributes are
+// skipped rather than reported as errors.
+func (l *Loader) addRequiredInput(block *configBlock, ty cty.Type) {
+	name := block.Labels[0]
+	key := l.address("var." + name)
+
+	input := &types.RequiredInput{
+		Name:   name,
+		Module: l.modulePath,
+		Type:   "any",
+		UsedBy: []types.InputUsage{},
+	}
+	if attr, ok := block.Body.Attributes["type"]; ok {
+		input.Type = l.exprSource(attr.Expr)
+	}
+	if attr, ok := block.Body.Attributes["description"]; ok {
+		val, diags := attr.Expr.Value(nil)
+		if !diags.HasErrors() && val.IsKnown() && !val.IsNull() && val.Type() == cty.String {
+			input.Description = val.AsString()
+		}
+	}
+
+	l.required[key] = input
+	l.inputSources["var."+name] = []string{key}
+	l.variables[name] = cty.UnknownVal(ty)
+}
+
+// referenceSources returns the required inputs an expression depends on
+// through the variables and locals it references
+func (l *Loader) referenceSources(expr hcl.Expression) []string {
+	var sources []string
+	seen := make(map[string]bool)
+	for _, traversal := range expr.Variables() {
+		root := traversal.RootName()
+		if (root != "var" && root != "local") || len(traversal) < 2 {
+			continue
+		}
+		attr, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok {
+			continue
+		}
+		for _, key := range l.inputSources[root+"."+attr.Name] {
+			if !seen[key] {
+				seen[key] = true
+				sources = append(sources, key)
+			}
+		}
+	}
+	return sources
+}
+
+// recordInputUsage notes every attribute of a resource body that depends on
+// a required input. Nested attributes are named by their block path, e.g.
+// root_block_device.volume_size.
+func (l *Loader) recordInputUsage(body *configBody, address, resourceType, prefix string) {
+	for name, attr := range body.Attributes {
+		for _, key := range l.referenceSources(attr.Expr) {
+			input := l.required[key]
+			input.UsedBy = append(input.UsedBy, types.InputUsage{
+				Address:      address,
+				ResourceType: resourceType,
+				Attribute:    prefix + name,
+			})
+		}
+	}
+	for _, block := range body.Blocks {
+		nested := prefix + nestedBlockType(block) + "."
+		if block.Type == "content" {
+			nested = prefix
+		}
+		l.recordInputUsage(block.Body, address, resourceType, nested)
+	}
+}
+
+// requiredInputs returns the recorded required inputs, root module first
+func requiredInputs(required map[string]*types.RequiredInput) []types.RequiredInput {
+	inputs := make([]types.RequiredInput, 0, len(required))
+	for _, r := range required {
+		input := *r
+		sort.Slice(input.UsedBy, func(i, j int) bool {
+			if input.UsedBy[i].Address != input.UsedBy[j].Address {
+				return input.UsedBy[i].Address < input.UsedBy[j].Address
+			}
+			return input.UsedBy[i].Attribute < input.UsedBy[j].Attribute
+		})
+		inputs = append(inputs, input)
+	}
+
+	sort.Slice(inputs, func(i, j int) bool {
+		if inputs[i].Module != inputs[j].Module {
+			return inputs[i].Module < inputs[j].Module
+		}
+		return inputs[i].Name < inputs[j].Name
+	})
+	return inputs
+}
+
+// exprSource returns the source text of an expression. JSON syntax
+// expressions are strings, so their quotes are removed.
+func (l *Loader) exprSource(expr hcl.Expression) string {
+	rng := expr.Range()
+	src := strings.TrimSpace(string(rng.SliceBytes(l.parser.Sources()[rng.Filename])))
+	if strings.HasSuffix(rng.Filename, ".json") {
+		if unquoted, err := strconv.Unquote(src); err == nil {
+			src = unquoted
+		}
+	}
+	return src
+}
@@ -3,6 +3,7 @@ package terraform
 
 import (
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,57 +12,115 @@ import (
 	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
 
 	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
 )
 
-// Loader parses Terraform configurations and expands into resources
+// Loader parses Terraform configurations and expands into resources. A
+// Loader holds the state of the load in progress and is not safe for
+// concurrent use; create one per load.
 type Loader struct {
 	parser    *hclparse.Parser
 	variables map[string]cty.Value
 	locals    map[string]cty.Value
+	modules   map[string]cty.Value
+	outputs   map[string]cty.Value
+	providers map[string]string // provider configuration key (aws, aws.west) -> region
 	evalCtx   *hcl.EvalContext
+	functions map[string]function.Function
+
+	// inputs holds the arguments passed by the calling module block
+	inputs map[string]cty.Value
+	// rootDir is the top of the uploaded configuration; module sources may not escape it
+	rootDir string
+	// dir is the directory of the module being evaluated
+	dir string
+	// modulePath is the address prefix of the module being evaluated (e.g. module.network)
+	modulePath string
+	// callStack holds the module directories currently being evaluated
+	callStack []string
+	// configs holds the parsed configuration of each directory in the
+	// upload, with override files applied; shared with child modules
+	configs map[string]*moduleConfig
+	// required holds variables with no value, keyed by address; shared with child modules
+	required map[string]*types.RequiredInput
+	// inputSources maps var.x and local.y to the required inputs their values depend on
+	inputSources map[string][]string
+	// data holds data source values by type and name
+	data map[string]map[string]cty.Value
+	// platforms holds the image platforms of aws_ami data sources by address
+	platforms map[string]*types.Platform
+	// resolvers supply data source attributes by data source type
+	resolvers map[string]DataSourceResolver
+	// metadata holds the known images, zones and account for resolvers
+	metadata *Metadata
+}
+
+// moduleConfig holds the blocks of a single module, collected before evaluation
+type moduleConfig struct {
+	variables   []*configBlock
+	locals      []*configBlock
+	resources   []*configBlock
+	dataSources []*configBlock
+	outputs     []*configBlock
+	moduleCalls []*configBlock
+	providers   []*configBlock
 }
 
 // NewLoader creates a new Terraform loader
 func NewLoader() *Loader {
-	return &Loader{
+	l := &Loader{
 		parser:    hclparse.NewParser(),
 		variables: make(map[string]cty.Value),
 		locals:    make(map[string]cty.Value),
+		modules:   make(map[string]cty.Value),
+		outputs:   make(map[string]cty.Value),
+		providers: make(map[string]string),
+		data:      make(map[string]map[string]cty.Value),
+		platforms: make(map[string]*types.Platform),
 	}
+	l.UseMetadata(builtinMetadata())
+	return l
 }
 
-// LoadDirectory loads all .tf files from a directory
-func (l *Loader) LoadDirectory(dir string) (*types.TerraformPlan, error) {
-	plan := &types.TerraformPlan{
-		Resources:   []types.TerraformResource{},
-		Variables:   make(map[string]interface{}),
-		Locals:      make(map[string]interface{}),
-		DataSources: []types.TerraformResource{},
-		Outputs:     make(map[string]interface{}),
-		Modules:     []string{},
-	}
+// LoadDirectory loads all .tf and .tf.json files from a directory, binding
+// root module variables from var files and explicit values in opts
+func (l *Loader) LoadDirectory(dir string, opts LoadOptions) (*types.TerraformPlan, error) {
+	plan := newPlan()
+
+	// Start from a clean scope so nothing leaks between loads
+	l.parser = hclparse.NewParser()
+	l.reset(filepath.Clean(dir), filepath.Clean(dir))
 
-	// Find all .tf files
+	// Find all configuration files, grouped by directory
+	filesByDir := make(map[string][]string)
+	var dirs []string
+	var terragruntFiles []string
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip directories and non-.tf files
+		// Skip directories and non-configuration files
 		if info.IsDir() {
 			// Skip hidden directories and terraform cache
-			if strings.HasPrefix(info.Name(), ".") || info.Name() == ".terraform" {
+			if path != dir && (strings.HasPrefix(info.Name(), ".") || info.Name() == ".terraform") {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		if strings.HasSuffix(path, ".tf") {
-			if err := l.parseFile(path, plan); err != nil {
-				return fmt.Errorf("failed to parse %s: %w", path, err)
+		if info.Name() == terragruntFile {
+			terragruntFiles = append(terragruntFiles, path)
+		}
+
+		if isConfigFile(path) {
+			d := filepath.Dir(path)
+			if _, seen := filesByDir[d]; !seen {
+				dirs = append(dirs, d)
 			}
+			filesByDir[d] = append(filesByDir[d], path)
 		}
 
 		return nil
@@ -71,181 +130,582 @@ func (l *Loader) LoadDirectory(dir string) (*types.TerraformPlan, error) {
 		return nil, err
 	}
 
-	// Build evaluation context and expand resources
-	l.buildEvalContext()
-	l.expandResources(plan)
+	// Parse everything up front so directories used as local module
+	// sources can be excluded from the root module, and each directory is
+	// parsed once however many modules or units use it
+	configs := make(map[string]*moduleConfig)
+	parsed := 0
+	for _, d := range dirs {
+		cfg := &moduleConfig{}
+		overrides := &moduleConfig{}
+		for _, path := range filesByDir[d] {
+			target := cfg
+			if isOverrideFile(path) {
+				target = overrides
+			}
+			diags := l.parseFile(path, target)
+			l.addDiagnostics(plan, diags, "")
+			if !diags.HasErrors() {
+				parsed++
+			}
+		}
+		l.addDiagnostics(plan, cfg.applyOverrides(overrides), "")
+		configs[d] = cfg
+	}
+	l.configs = configs
+
+	// Terragrunt uploads are a set of units, each priced as its own stack
+	if isTerragrunt(l.rootDir, terragruntFiles, filesByDir) {
+		return l.loadTerragrunt(terragruntFiles, opts, plan)
+	}
+
+	// Partial results are only useful if something could be parsed
+	if parsed == 0 && len(plan.Diagnostics) > 0 {
+		d := plan.Diagnostics[0]
+		if d.Range != nil {
+			return nil, fmt.Errorf("failed to parse %s:%d: %s", d.Range.Filename, d.Range.StartLine, d.Summary)
+		}
+		return nil, fmt.Errorf("failed to parse configuration: %s", d.Summary)
+	}
+
+	moduleDirs := make(map[string]bool)
+	for d, cfg := range configs {
+		for _, block := range cfg.moduleCalls {
+			if source, ok := localModuleSource(block); ok {
+				moduleDirs[filepath.Clean(filepath.Join(d, source))] = true
+			}
+		}
+	}
+
+	root := &moduleConfig{}
+	for _, d := range dirs {
+		if moduleDirs[d] {
+			continue
+		}
+		root.merge(configs[d])
+	}
+
+	// Bind root variables from var files and explicit values
+	inputs, err := l.rootVariableValues(l.rootDir, opts, plan)
+	if err != nil {
+		return nil, err
+	}
+	l.inputs = inputs
+
+	// Evaluate the root module
+	l.evaluate(root, plan)
+	plan.RequiredInputs = requiredInputs(l.required)
+	plan.References = resolveReferences(plan)
 
 	return plan, nil
 }
 
-// parseFile parses a single .tf file
-func (l *Loader) parseFile(path string, plan *types.TerraformPlan) error {
+// isConfigFile reports whether a file holds Terraform configuration in
+// native (.tf) or JSON (.tf.json) syntax
+func isConfigFile(path string) bool {
+	return strings.HasSuffix(path, ".tf") || strings.HasSuffix(path, ".tf.json")
+}
+
+// newPlan returns an empty plan
+func newPlan() *types.TerraformPlan {
+	return &types.TerraformPlan{
+		Resources:   []types.TerraformResource{},
+		Variables:   make(map[string]interface{}),
+		Locals:      make(map[string]interface{}),
+		DataSources: []types.TerraformResource{},
+		Outputs:     make(map[string]interface{}),
+		Modules:     []string{},
+	}
+}
+
+// reset starts a clean evaluation scope for a root module in dir
+func (l *Loader) reset(rootDir, dir string) {
+	l.variables = make(map[string]cty.Value)
+	l.locals = make(map[string]cty.Value)
+	l.modules = make(map[string]cty.Value)
+	l.outputs = make(map[string]cty.Value)
+	l.providers = make(map[string]string)
+	l.evalCtx = nil
+	l.functions = nil
+	l.inputs = nil
+	l.rootDir = rootDir
+	l.dir = dir
+	l.modulePath = ""
+	l.callStack = nil
+	l.required = make(map[string]*types.RequiredInput)
+	l.inputSources = make(map[string][]string)
+	l.data = make(map[string]map[string]cty.Value)
+	l.platforms = make(map[string]*types.Platform)
+}
+
+// parseFile parses a single .tf or .tf.json file and collects its blocks. A file with
+// errors contributes no blocks, so the rest of the configuration can still
+// be evaluated.
+func (l *Loader) parseFile(path string, cfg *moduleConfig) hcl.Diagnostics {
 	src, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to read file",
+			Detail:   err.Error(),
+			Subject:  &hcl.Range{Filename: path},
+		}}
 	}
+	return l.parseSource(src, path, cfg)
+}
 
-	file, diags := l.parser.ParseHCL(src, path)
-	if diags.HasErrors() {
-		return fmt.Errorf("parse errors: %s", diags.Error())
-	}
+// parseSource parses configuration source named path and collects its blocks
+func (l *Loader) parseSource(src []byte, path string, cfg *moduleConfig) hcl.Diagnostics {
+	var blocks []*configBlock
+	var diags hcl.Diagnostics
+	if strings.HasSuffix(path, ".tf.json") {
+		var file *hcl.File
+		file, diags = l.parser.ParseJSON(src, path)
+		if diags.HasErrors() {
+			return diags
+		}
 
-	body, ok := file.Body.(*hclsyntax.Body)
-	if !ok {
-		return fmt.Errorf("unexpected body type")
+		var blockDiags hcl.Diagnostics
+		blocks, blockDiags = jsonBlocks(file.Body)
+		diags = append(diags, blockDiags...)
+		if diags.HasErrors() {
+			return diags
+		}
+	} else {
+		var file *hcl.File
+		file, diags = l.parser.ParseHCL(src, path)
+		if diags.HasErrors() {
+			return diags
+		}
+
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			return diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unexpected body type",
+				Subject:  &hcl.Range{Filename: path},
+			})
+		}
+		for _, block := range body.Blocks {
+			blocks = append(blocks, nativeBlock(block))
+		}
 	}
 
 	// Extract blocks
-	for _, block := range body.Blocks {
+	for _, block := range blocks {
 		switch block.Type {
 		case "variable":
-			l.parseVariable(block, plan)
+			cfg.variables = append(cfg.variables, block)
 		case "locals":
-			l.parseLocals(block, plan)
+			cfg.locals = append(cfg.locals, block)
 		case "resource":
-			l.parseResource(block, plan)
+			cfg.resources = append(cfg.resources, block)
 		case "data":
-			l.parseDataSource(block, plan)
+			cfg.dataSources = append(cfg.dataSources, block)
 		case "output":
-			l.parseOutput(block, plan)
+			cfg.outputs = append(cfg.outputs, block)
 		case "module":
-			plan.Modules = append(plan.Modules, block.Labels[0])
+			cfg.moduleCalls = append(cfg.moduleCalls, block)
+		case "provider":
+			cfg.providers = append(cfg.providers, block)
+		}
+	}
+
+	return diags
+}
+
+// merge appends all blocks of other to cfg
+func (cfg *moduleConfig) merge(other *moduleConfig) {
+	cfg.variables = append(cfg.variables, other.variables...)
+	cfg.locals = append(cfg.locals, other.locals...)
+	cfg.resources = append(cfg.resources, other.resources...)
+	cfg.dataSources = append(cfg.dataSources, other.dataSources...)
+	cfg.outputs = append(cfg.outputs, other.outputs...)
+	cfg.moduleCalls = append(cfg.moduleCalls, other.moduleCalls...)
+	cfg.providers = append(cfg.providers, other.providers...)
+}
+
+// evaluate evaluates the collected blocks of a module into the plan.
+// Variables are resolved first, then locals, providers, module calls and
+// data sources in dependency order, so that count, for_each and resource
+// attributes see a complete evaluation context.
+func (l *Loader) evaluate(cfg *moduleConfig, plan *types.TerraformPlan) {
+	declared := make(map[string]bool)
+	for _, block := range cfg.variables {
+		l.parseVariable(block, plan)
+		if len(block.Labels) > 0 {
+			declared[block.Labels[0]] = true
 		}
 	}
+	for name := range l.inputs {
+		if !declared[name] {
+			l.addDiagnostic(plan, &hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Value for undeclared variable",
+				Detail:   fmt.Sprintf("A value was provided for %q, which is not declared in %s.", name, l.moduleName()),
+			}, "")
+		}
+	}
+	l.buildEvalContext()
 
-	return nil
+	for _, node := range l.orderDependencies(cfg, plan) {
+		switch {
+		case node.cyclic && node.local != nil:
+			l.locals[node.name] = cty.DynamicVal
+		case node.cyclic && node.provider != nil:
+			l.providers[node.name] = ""
+		case node.cyclic && node.data != nil:
+			l.setData(node.data, cty.DynamicVal)
+		case node.cyclic:
+			l.modules[node.name] = cty.DynamicVal
+		case node.local != nil:
+			l.parseLocal(node.name, node.local, plan)
+		case node.provider != nil:
+			l.parseProvider(node.provider, plan)
+		case node.data != nil:
+			l.parseDataSource(node.data, plan)
+		default:
+			l.parseModule(node.module, plan)
+		}
+		l.buildEvalContext()
+	}
+
+	for _, block := range cfg.resources {
+		l.parseResource(block, plan)
+	}
+	for _, block := range cfg.outputs {
+		l.parseOutput(block, plan)
+	}
 }
 
 // parseVariable extracts variable definitions
-func (l *Loader) parseVariable(block *hclsyntax.Block, plan *types.TerraformPlan) {
+func (l *Loader) parseVariable(block *configBlock, plan *types.TerraformPlan) {
 	if len(block.Labels) == 0 {
 		return
 	}
 
 	name := block.Labels[0]
-	
-	// Extract default value if present
-	for _, attr := range block.Body.Attributes {
-		if attr.Name == "default" {
-			val, _ := attr.Expr.Value(nil)
-			l.variables[name] = val
-			plan.Variables[name] = ctyToGo(val)
+
+	// Values from var files or the calling module block take precedence
+	val, ok := l.inputs[name]
+	if !ok {
+		// Extract default value if present
+		attr, hasDefault := block.Body.Attributes["default"]
+		if !hasDefault {
+			ty, _, diags := variableType(block)
+			if diags.HasErrors() {
+				ty = cty.DynamicPseudoType
+			}
+			l.addRequiredInput(block, ty)
+			return
 		}
+		var diags hcl.Diagnostics
+		val, diags = attr.Expr.Value(nil)
+		l.addDiagnostics(plan, diags, l.address("var."+name))
+	}
+
+	// Convert to the declared type, e.g. "3" from a var file to a number
+	ty, defaults, diags := variableType(block)
+	if !diags.HasErrors() {
+		converted, err := convertVariable(val, ty, defaults)
+		if err != nil {
+			l.addDiagnostic(plan, &hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Invalid value for variable",
+				Detail:   fmt.Sprintf("The value for var.%s does not match its type: %s.", name, err),
+				Subject:  block.DefRange.Ptr(),
+			}, l.address("var."+name))
+		} else {
+			val = converted
+		}
+	}
+
+	l.variables[name] = val
+	if l.modulePath == "" {
+		plan.Variables[name] = ctyToGo(val)
 	}
 }
 
-// parseLocals extracts local values
-func (l *Loader) parseLocals(block *hclsyntax.Block, plan *types.TerraformPlan) {
-	for name, attr := range block.Body.Attributes {
-		// Locals will be evaluated later with context
-		val, _ := attr.Expr.Value(l.evalCtx)
-		l.locals[name] = val
+// parseLocal evaluates a single local value
+func (l *Loader) parseLocal(name string, attr *hcl.Attribute, plan *types.TerraformPlan) {
+	val, diags := attr.Expr.Value(l.evalCtx)
+	l.addDiagnostics(plan, diags, l.address("local."+name))
+	l.inputSources["local."+name] = l.referenceSources(attr.Expr)
+	l.locals[name] = val
+	if l.modulePath == "" {
 		plan.Locals[name] = ctyToGo(val)
 	}
 }
 
-// parseResource extracts resource definitions
-func (l *Loader) parseResource(block *hclsyntax.Block, plan *types.TerraformPlan) {
+// parseResource extracts resource definitions, one per count/for_each instance
+func (l *Loader) parseResource(block *configBlock, plan *types.TerraformPlan) {
 	if len(block.Labels) < 2 {
 		return
 	}
 
 	resourceType := block.Labels[0]
 	resourceName := block.Labels[1]
+	address := l.address(fmt.Sprintf("%s.%s", resourceType, resourceName))
+
+	// Determine provider from resource type
+	provider := strings.Split(resourceType, "_")[0]
+	region := l.resourceRegion(block.Body, provider)
 
-	resource := types.TerraformResource{
-		Type:    resourceType,
-		Name:    resourceName,
-		Address: fmt.Sprintf("%s.%s", resourceType, resourceName),
-		Config:  make(map[string]interface{}),
-		Count:   1,
+	l.recordInputUsage(block.Body, address, resourceType, "")
+
+	instances, forEachKeys, diags := l.expandInstances(block.Body)
+	l.addDiagnostics(plan, diags, address)
+	if len(instances) == 0 {
+		if !diags.HasErrors() {
+			l.addDisabled(block.Body, address, resourceType, resourceName, plan)
+		}
+		return
 	}
+	for _, inst := range instances {
+		resource := types.TerraformResource{
+			Type:     resourceType,
+			Name:     resourceName,
+			Address:  address + inst.suffix,
+			Provider: provider,
+			Region:   region,
+			Config:   make(map[string]interface{}),
+			Count:    1,
+			ForEach:  forEachKeys,
+			Module:   l.modulePath,
+			Source:   l.sourceRange(block.DefRange),
+		}
 
-	// Determine provider from resource type
-	parts := strings.Split(resourceType, "_")
-	if len(parts) > 0 {
-		resource.Provider = parts[0]
+		l.addDiagnostics(plan, evalBody(block.Body, inst.ctx, resource.Config), resource.Address)
+		resource.Platform = l.resourcePlatform(block.Body, resource.Config)
+		l.recordReferences(block.Body, resource.Address, inst.ctx, "", plan)
+
+		plan.Resources = append(plan.Resources, resource)
 	}
+}
+
+// resourceMetaArgs are resource arguments that are not part of its configuration
+var resourceMetaArgs = map[string]bool{
+	"for_each":   true,
+	"provider":   true,
+	"depends_on": true,
+}
+
+// evalBody evaluates the attributes and nested blocks of a resource body into config
+func evalBody(body *configBody, ctx *hcl.EvalContext, config map[string]interface{}) hcl.Diagnostics {
+	var diags hcl.Diagnostics
 
 	// Extract attributes
-	for attrName, attr := range block.Body.Attributes {
-		val, _ := attr.Expr.Value(l.evalCtx)
-		
+	for attrName, attr := range body.Attributes {
+		if resourceMetaArgs[attrName] {
+			continue
+		}
+
+		val, valDiags := attr.Expr.Value(ctx)
+		diags = append(diags, valDiags...)
+
 		// Skip unknown values (e.g., variables without defaults, computed values)
 		if !val.IsKnown() {
 			continue
 		}
-		
-		resource.Config[attrName] = ctyToGo(val)
 
-		// Handle count
-		if attrName == "count" {
-			if val.Type() == cty.Number {
-				f := val.AsBigFloat()
-				count, _ := f.Int64()
-				resource.Count = int(count)
-			}
-		}
+		config[attrName] = ctyToGo(val)
 	}
 
 	// Check for nested blocks (like ebs_block_device, tags, etc.)
-	for _, nestedBlock := range block.Body.Blocks {
-		blockConfig := make(map[string]interface{})
-		for attrName, attr := range nestedBlock.Body.Attributes {
-			val, _ := attr.Expr.Value(l.evalCtx)
-			
-			// Skip unknown values
-			if !val.IsKnown() {
-				continue
-			}
-			
-			blockConfig[attrName] = ctyToGo(val)
+	return append(diags, evalNestedBlocks(body, ctx, config)...)
+}
+
+// evalNestedBlocks evaluates the nested blocks of a body into config.
+// A single block of a type becomes a map, repeated blocks a list of maps.
+func evalNestedBlocks(body *configBody, ctx *hcl.EvalContext, config map[string]interface{}) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, nestedBlock := range body.Blocks {
+		if nestedBlock.Type == "dynamic" {
+			diags = append(diags, evalDynamicBlock(nestedBlock, ctx, config)...)
+			continue
 		}
+		blockConfig, blockDiags := evalBlockBody(nestedBlock.Body, ctx)
+		diags = append(diags, blockDiags...)
+		addNestedBlock(config, nestedBlock.Type, blockConfig)
+	}
+	return diags
+}
 
-		// Handle multiple nested blocks of same type
-		if existing, ok := resource.Config[nestedBlock.Type]; ok {
-			if arr, isArr := existing.([]interface{}); isArr {
-				resource.Config[nestedBlock.Type] = append(arr, blockConfig)
-			} else {
-				resource.Config[nestedBlock.Type] = []interface{}{existing, blockConfig}
-			}
-		} else {
-			resource.Config[nestedBlock.Type] = blockConfig
+// evalBlockBody evaluates the attributes and nested blocks of a nested block
+func evalBlockBody(body *configBody, ctx *hcl.EvalContext) (map[string]interface{}, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	blockConfig := make(map[string]interface{})
+	for attrName, attr := range body.Attributes {
+		val, valDiags := attr.Expr.Value(ctx)
+		diags = append(diags, valDiags...)
+
+		// Skip unknown values
+		if !val.IsKnown() {
+			continue
 		}
+
+		blockConfig[attrName] = ctyToGo(val)
+	}
+	diags = append(diags, evalNestedBlocks(body, ctx, blockConfig)...)
+	return blockConfig, diags
+}
+
+// evalDynamicBlock expands a dynamic block into one nested block per
+// for_each element, with the iterator variable bound in its content
+func evalDynamicBlock(block *configBlock, ctx *hcl.EvalContext, config map[string]interface{}) hcl.Diagnostics {
+	if len(block.Labels) == 0 {
+		return nil
 	}
+	blockType := block.Labels[0]
 
-	plan.Resources = append(plan.Resources, resource)
+	var content *configBlock
+	for _, b := range block.Body.Blocks {
+		if b.Type == "content" {
+			content = b
+			break
+		}
+	}
+	forEachAttr, ok := block.Body.Attributes["for_each"]
+	if content == nil || !ok {
+		return nil
+	}
+
+	// The iterator defaults to the block type name
+	iterator := blockType
+	if attr, ok := block.Body.Attributes["iterator"]; ok {
+		if name := hcl.ExprAsKeyword(attr.Expr); name != "" {
+			iterator = name
+		}
+	}
+
+	forEach, diags := forEachAttr.Expr.Value(ctx)
+	if diags.HasErrors() {
+		return diags
+	}
+	if !forEach.IsWhollyKnown() || forEach.IsNull() || !forEach.CanIterateElements() {
+		return diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Dynamic block not expanded",
+			Detail:   fmt.Sprintf("The for_each of dynamic %q is not a known collection, so its blocks are omitted.", blockType),
+			Subject:  forEachAttr.Expr.Range().Ptr(),
+		})
+	}
+
+	for it := forEach.ElementIterator(); it.Next(); {
+		key, value := it.Element()
+		childCtx := ctx.NewChild()
+		childCtx.Variables = map[string]cty.Value{
+			iterator: cty.ObjectVal(map[string]cty.Value{
+				"key":   key,
+				"value": value,
+			}),
+		}
+		blockConfig, blockDiags := evalBlockBody(content.Body, childCtx)
+		diags = append(diags, blockDiags...)
+		addNestedBlock(config, blockType, blockConfig)
+	}
+	return diags
+}
+
+// addNestedBlock adds a nested block's config, turning repeated blocks of
+// the same type into a list
+func addNestedBlock(config map[string]interface{}, blockType string, blockConfig map[string]interface{}) {
+	if existing, ok := config[blockType]; ok {
+		if arr, isArr := existing.([]interface{}); isArr {
+			config[blockType] = append(arr, blockConfig)
+		} else {
+			config[blockType] = []interface{}{existing, blockConfig}
+		}
+	} else {
+		config[blockType] = blockConfig
+	}
 }
 
-// parseDataSource extracts data source definitions
-func (l *Loader) parseDataSource(block *hclsyntax.Block, plan *types.TerraformPlan) {
+// parseDataSource extracts data source definitions and resolves their
+// values, one per count/for_each instance
+func (l *Loader) parseDataSource(block *configBlock, plan *types.TerraformPlan) {
 	if len(block.Labels) < 2 {
 		return
 	}
 
-	dataSource := types.TerraformResource{
-		Type:    block.Labels[0],
-		Name:    block.Labels[1],
-		Address: fmt.Sprintf("data.%s.%s", block.Labels[0], block.Labels[1]),
-		Config:  make(map[string]interface{}),
+	localAddress := fmt.Sprintf("data.%s.%s", block.Labels[0], block.Labels[1])
+	address := l.address(localAddress)
+	provider := strings.Split(block.Labels[0], "_")[0]
+	region := l.resourceRegion(block.Body, provider)
+
+	// References to data sources that cannot be resolved are unknown
+	l.setData(block, cty.DynamicVal)
+
+	instances, forEachKeys, diags := l.expandInstances(block.Body)
+	l.addDiagnostics(plan, diags, address)
+	_, hasCount := block.Body.Attributes["count"]
+
+	var values []cty.Value
+	for _, inst := range instances {
+		dataSource := types.TerraformResource{
+			Type:     block.Labels[0],
+			Name:     block.Labels[1],
+			Address:  address + inst.suffix,
+			Provider: provider,
+			Region:   region,
+			Config:   make(map[string]interface{}),
+			ForEach:  forEachKeys,
+			Module:   l.modulePath,
+			Source:   l.sourceRange(block.DefRange),
+		}
+
+		l.addDiagnostics(plan, evalBody(block.Body, inst.ctx, dataSource.Config), dataSource.Address)
+
+		val := l.resolveDataSource(dataSource)
+		if dataSource.Type == "aws_ami" {
+			if p := dataSourcePlatform(dataSource, val, address); p != nil {
+				l.platforms[localAddress] = p
+			}
+		}
+		values = append(values, val)
+
+		plan.DataSources = append(plan.DataSources, dataSource)
 	}
 
-	for attrName, attr := range block.Body.Attributes {
-		val, _ := attr.Expr.Value(l.evalCtx)
-		
-		// Skip unknown values
-		if !val.IsKnown() {
-			continue
+	// Instances are shaped like module instances: a single object, a tuple
+	// for count or an object keyed by for_each key
+	switch {
+	case forEachKeys != nil:
+		byKey := make(map[string]cty.Value, len(forEachKeys))
+		for i, key := range forEachKeys {
+			byKey[key] = values[i]
 		}
-		
-		dataSource.Config[attrName] = ctyToGo(val)
+		l.setData(block, cty.ObjectVal(byKey))
+	case hasCount && len(values) == 0:
+		l.setData(block, cty.EmptyTupleVal)
+	case hasCount && instances[0].suffix != "":
+		l.setData(block, cty.TupleVal(values))
+	case !hasCount && len(values) == 1:
+		l.setData(block, values[0])
+	}
+}
+
+// setData records the value of a data source for references
+func (l *Loader) setData(block *configBlock, val cty.Value) {
+	byName, ok := l.data[block.Labels[0]]
+	if !ok {
+		byName = make(map[string]cty.Value)
+		l.data[block.Labels[0]] = byName
 	}
+	byName[block.Labels[1]] = val
+}
 
-	plan.DataSources = append(plan.DataSources, dataSource)
+// dataValue returns the data sources evaluated so far as the data object
+func (l *Loader) dataValue() cty.Value {
+	byType := make(map[string]cty.Value, len(l.data))
+	for dataType, byName := range l.data {
+		byType[dataType] = cty.ObjectVal(byName)
+	}
+	return cty.ObjectVal(byType)
 }
 
 // parseOutput extracts output definitions
-func (l *Loader) parseOutput(block *hclsyntax.Block, plan *types.TerraformPlan) {
+func (l *Loader) parseOutput(block *configBlock, plan *types.TerraformPlan) {
 	if len(block.Labels) == 0 {
 		return
 	}
@@ -253,54 +713,206 @@ func (l *Loader) parseOutput(block *hclsyntax.Block, plan *types.TerraformPlan)
 	name := block.Labels[0]
 	for attrName, attr := range block.Body.Attributes {
 		if attrName == "value" {
-			val, _ := attr.Expr.Value(l.evalCtx)
-			
+			val, diags := attr.Expr.Value(l.evalCtx)
+			l.addDiagnostics(plan, diags, l.address("output."+name))
+			l.outputs[name] = val
+
 			// Skip unknown values
 			if !val.IsKnown() {
 				continue
 			}
-			
-			plan.Outputs[name] = ctyToGo(val)
+
+			if l.modulePath == "" {
+				plan.Outputs[name] = ctyToGo(val)
+			}
 		}
 	}
 }
 
-// buildEvalContext creates the HCL evaluation context with variables and locals
+// buildEvalContext creates the HCL evaluation context with variables,
+// locals, module outputs and the Terraform function library
 func (l *Loader) buildEvalContext() {
+	if l.functions == nil {
+		l.functions = functions(l.rootDir, l.dir)
+	}
+
 	l.evalCtx = &hcl.EvalContext{
 		Variables: map[string]cty.Value{
-			"var":   cty.ObjectVal(l.variables),
-			"local": cty.ObjectVal(l.locals),
+			"var":    cty.ObjectVal(l.variables),
+			"local":  cty.ObjectVal(l.locals),
+			"module": cty.ObjectVal(l.modules),
+			"data":   l.dataValue(),
+			"path": cty.ObjectVal(map[string]cty.Value{
+				"module": cty.StringVal(l.dir),
+				"root":   cty.StringVal(l.rootDir),
+				"cwd":    cty.StringVal(l.rootDir),
+			}),
+			"terraform": cty.ObjectVal(map[string]cty.Value{
+				"workspace": cty.StringVal("default"),
+			}),
 		},
+		Functions: l.functions,
+	}
+}
+
+// moduleName describes the module being evaluated for messages
+func (l *Loader) moduleName() string {
+	if l.modulePath == "" {
+		return "the root module"
 	}
+	return l.modulePath
 }
 
-// expandResources expands count and for_each into individual resources
-func (l *Loader) expandResources(plan *types.TerraformPlan) {
-	var expanded []types.TerraformResource
+// address prefixes a resource address with the current module path
+func (l *Loader) address(addr string) string {
+	if l.modulePath == "" {
+		return addr
+	}
+	return l.modulePath + "." + addr
+}
+
+// instance is a single count or for_each instance of a resource or module
+type instance struct {
+	suffix string           // address suffix, e.g. [0] or ["api"]
+	ctx    *hcl.EvalContext // evaluation context with count/each bound
+}
 
-	for _, res := range plan.Resources {
-		if res.Count > 1 {
-			// Expand count into multiple resources
-			for i := 0; i < res.Count; i++ {
-				copy := res
-				copy.Address = fmt.Sprintf("%s[%d]", res.Address, i)
-				copy.Count = 1
-				expanded = append(expanded, copy)
+// maxCount is the largest count a resource, data source or module may have
+const maxCount = 10000
+
+// expandInstances evaluates count and for_each on a block body and returns
+// one instance per element, along with the for_each keys if any and any
+// diagnostics from evaluating them
+func (l *Loader) expandInstances(body *configBody) ([]instance, []string, hcl.Diagnostics) {
+	single := []instance{{ctx: l.evalCtx}}
+
+	if attr, ok := body.Attributes["for_each"]; ok {
+		val, diags := attr.Expr.Value(l.evalCtx)
+		keys, values, ok := forEachElements(val)
+		if !ok {
+			// Unknown collection: fall back to a single instance with
+			// each.key/each.value unknown so dependent attributes are skipped
+			ctx := l.evalCtx.NewChild()
+			ctx.Variables = map[string]cty.Value{
+				"each": cty.ObjectVal(map[string]cty.Value{
+					"key":   cty.UnknownVal(cty.String),
+					"value": cty.DynamicVal,
+				}),
 			}
-		} else if len(res.ForEach) > 0 {
-			// Expand for_each into multiple resources
-			for _, key := range res.ForEach {
-				copy := res
-				copy.Address = fmt.Sprintf("%s[\"%s\"]", res.Address, key)
-				expanded = append(expanded, copy)
+			return []instance{{ctx: ctx}}, nil, diags
+		}
+
+		instances := make([]instance, 0, len(keys))
+		for i, key := range keys {
+			ctx := l.evalCtx.NewChild()
+			ctx.Variables = map[string]cty.Value{
+				"each": cty.ObjectVal(map[string]cty.Value{
+					"key":   cty.StringVal(key),
+					"value": values[i],
+				}),
 			}
-		} else {
-			expanded = append(expanded, res)
+			instances = append(instances, instance{
+				suffix: fmt.Sprintf("[%q]", key),
+				ctx:    ctx,
+			})
 		}
+		return instances, keys, diags
+	}
+
+	if attr, ok := body.Attributes["count"]; ok {
+		val, diags := attr.Expr.Value(l.evalCtx)
+		if !val.IsKnown() || val.IsNull() || val.Type() != cty.Number {
+			return single, nil, diags
+		}
+
+		// Like Terraform, reject counts that are not whole numbers of
+		// instances; the cap keeps a typo from exhausting memory
+		count, acc := val.AsBigFloat().Int64()
+		if acc != big.Exact || count < 0 || count > maxCount {
+			return nil, nil, diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid count argument",
+				Detail: fmt.Sprintf("The count must be a whole number from 0 to %d, got %s.",
+					maxCount, val.AsBigFloat().Text('g', -1)),
+				Subject: attr.Expr.Range().Ptr(),
+			})
+		}
+		instances := make([]instance, 0, count)
+		for i := int64(0); i < count; i++ {
+			ctx := l.evalCtx.NewChild()
+			ctx.Variables = map[string]cty.Value{
+				"count": cty.ObjectVal(map[string]cty.Value{
+					"index": cty.NumberIntVal(i),
+				}),
+			}
+			instances = append(instances, instance{
+				suffix: fmt.Sprintf("[%d]", i),
+				ctx:    ctx,
+			})
+		}
+		return instances, nil, diags
+	}
+
+	return single, nil, nil
+}
+
+// addDisabled records a resource or module call whose count is 0 or whose
+// for_each is empty, with the expression that disabled it
+func (l *Loader) addDisabled(body *configBody, address, blockType, name string, plan *types.TerraformPlan) {
+	disabled := types.DisabledResource{
+		Address: address,
+		Type:    blockType,
+		Name:    name,
+		Module:  l.modulePath,
+	}
+
+	attr, ok := body.Attributes["for_each"]
+	if ok {
+		disabled.Reason = "for_each is empty"
+	} else if attr, ok = body.Attributes["count"]; ok {
+		disabled.Reason = "count is 0"
+	} else {
+		return
+	}
+
+	disabled.Condition = fmt.Sprintf("%s = %s", attr.Name, l.exprSource(attr.Expr))
+
+	plan.NotCreated = append(plan.NotCreated, disabled)
+}
+
+// forEachElements returns the keys and values of a for_each collection.
+// Maps and objects yield their entries; sets (and, leniently, lists) of
+// strings use each element as both key and value.
+func forEachElements(val cty.Value) ([]string, []cty.Value, bool) {
+	if val.IsNull() || !val.IsWhollyKnown() {
+		return nil, nil, false
+	}
+
+	ty := val.Type()
+	var keys []string
+	var values []cty.Value
+
+	switch {
+	case ty.IsMapType() || ty.IsObjectType():
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			keys = append(keys, k.AsString())
+			values = append(values, v)
+		}
+	case ty.IsSetType() || ty.IsListType() || ty.IsTupleType():
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			if v.IsNull() || v.Type() != cty.String {
+				return nil, nil, false
+			}
+			keys = append(keys, v.AsString())
+			values = append(values, v)
+		}
+	default:
+		return nil, nil, false
 	}
 
-	plan.Resources = expanded
+	return keys, values, true
 }
 
 // ctyToGo converts a cty.Value to a Go value
@@ -323,7 +935,7 @@ func ctyToGo(val cty.Value) interface{} {
 		return flt
 	case val.Type() == cty.Bool:
 		return val.True()
-	case val.Type().IsListType() || val.Type().IsTupleType():
+	case val.Type().IsListType() || val.Type().IsSetType() || val.Type().IsTupleType():
 		var arr []interface{}
 		for it := val.ElementIterator(); it.Next(); {
 			_, v := it.Element()
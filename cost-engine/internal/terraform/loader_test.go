@@ -0,0 +1,266 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// loadFiles writes files into a temporary directory and loads it
+func loadFiles(t *testing.T, files map[string]string) *types.TerraformPlan {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plan, err := NewLoader().LoadDirectory(dir, LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
+
+// resourceAddresses returns the sorted addresses of a plan's resources
+func resourceAddresses(plan *types.TerraformPlan) []string {
+	addresses := []string{}
+	for _, res := range plan.Resources {
+		addresses = append(addresses, res.Address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// hasError reports whether a plan has an error diagnostic with the summary
+func hasError(plan *types.TerraformPlan, summary string) bool {
+	for _, diag := range plan.Diagnostics {
+		if diag.Severity == types.SeverityError && diag.Summary == summary {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExpandInstances(t *testing.T) {
+	tests := []struct {
+		name       string
+		meta       string
+		addresses  []string
+		notCreated bool
+		diagnostic string
+	}{
+		{"no meta-argument", ``, []string{"aws_instance.web"}, false, ""},
+		{"count", `count = 2`, []string{"aws_instance.web[0]", "aws_instance.web[1]"}, false, ""},
+		{"count from expression", `count = length(["a", "b", "c"])`, []string{"aws_instance.web[0]", "aws_instance.web[1]", "aws_instance.web[2]"}, false, ""},
+		{"zero count", `count = 0`, []string{}, true, ""},
+		{"negative count", `count = -1`, []string{}, false, "Invalid count argument"},
+		{"fractional count", `count = 1.5`, []string{}, false, "Invalid count argument"},
+		{"oversized count", `count = 1e12`, []string{}, false, "Invalid count argument"},
+		{"for_each set", `for_each = toset(["b", "a"])`, []string{`aws_instance.web["a"]`, `aws_instance.web["b"]`}, false, ""},
+		{"for_each map", `for_each = { api = 1 }`, []string{`aws_instance.web["api"]`}, false, ""},
+		{"empty for_each", `for_each = {}`, []string{}, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := loadFiles(t, map[string]string{
+				"main.tf": "resource \"aws_instance\" \"web\" {\n" + tt.meta + "\ninstance_type = \"t3.micro\"\n}\n",
+			})
+
+			if got := resourceAddresses(plan); !reflect.DeepEqual(got, tt.addresses) {
+				t.Errorf("resources = %v, want %v", got, tt.addresses)
+			}
+			if got := len(plan.NotCreated) > 0; got != tt.notCreated {
+				t.Errorf("not created = %v, want %v", plan.NotCreated, tt.notCreated)
+			}
+			if tt.diagnostic != "" && !hasError(plan, tt.diagnostic) {
+				t.Errorf("missing %q diagnostic in %+v", tt.diagnostic, plan.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestEachValue(t *testing.T) {
+	plan := loadFiles(t, map[string]string{
+		"main.tf": `
+resource "aws_instance" "web" {
+  for_each      = { small = "t3.micro", large = "m5.large" }
+  instance_type = each.value
+  tags          = { Name = each.key }
+}
+`,
+	})
+
+	want := map[string]string{
+		`aws_instance.web["large"]`: "m5.large",
+		`aws_instance.web["small"]`: "t3.micro",
+	}
+	for _, res := range plan.Resources {
+		if res.Config["instance_type"] != want[res.Address] {
+			t.Errorf("%s: instance_type = %v, want %s", res.Address, res.Config["instance_type"], want[res.Address])
+		}
+	}
+}
+
+func TestOverrideFiles(t *testing.T) {
+	plan := loadFiles(t, map[string]string{
+		"main.tf": `
+locals {
+  env = "dev"
+}
+
+resource "aws_instance" "web" {
+  instance_type = "t3.micro"
+  ami           = "ami-123"
+  tags          = { Env = local.env }
+
+  ebs_block_device {
+    device_name = "/dev/sdb"
+    volume_size = 10
+  }
+  ebs_block_device {
+    device_name = "/dev/sdc"
+    volume_size = 20
+  }
+}
+`,
+		"override.tf": `
+resource "aws_instance" "web" {
+  instance_type = "m5.large"
+}
+`,
+		"prod_override.tf": `
+locals {
+  env = "prod"
+}
+
+resource "aws_instance" "web" {
+  ebs_block_device {
+    device_name = "/dev/sdd"
+    volume_size = 100
+  }
+}
+
+resource "aws_instance" "missing" {
+  instance_type = "m5.large"
+}
+`,
+	})
+
+	if got := resourceAddresses(plan); !reflect.DeepEqual(got, []string{"aws_instance.web"}) {
+		t.Fatalf("resources = %v, want only aws_instance.web", got)
+	}
+	config := plan.Resources[0].Config
+
+	// Arguments are replaced one by one; the rest are kept
+	if config["instance_type"] != "m5.large" || config["ami"] != "ami-123" {
+		t.Errorf("instance_type = %v, ami = %v; want m5.large, ami-123", config["instance_type"], config["ami"])
+	}
+	if tags, _ := config["tags"].(map[string]interface{}); tags["Env"] != "prod" {
+		t.Errorf("tags = %v, want the overridden local", config["tags"])
+	}
+
+	// Nested blocks of a type are replaced together
+	block, ok := config["ebs_block_device"].(map[string]interface{})
+	if !ok || block["device_name"] != "/dev/sdd" || fmt.Sprint(block["volume_size"]) != "100" {
+		t.Errorf("ebs_block_device = %#v, want only the /dev/sdd override", config["ebs_block_device"])
+	}
+
+	if !hasError(plan, "Missing base resource definition to override") {
+		t.Errorf("missing diagnostic for an override without a base block in %+v", plan.Diagnostics)
+	}
+}
+
+func TestModuleDiagnostics(t *testing.T) {
+	plan := loadFiles(t, map[string]string{
+		"main.tf": `
+module "registry" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+
+module "outside" {
+  source = "../shared"
+}
+
+module "app" {
+  source = "./app"
+}
+`,
+		"app/main.tf": `
+module "again" {
+  source = "../app"
+}
+`,
+	})
+
+	want := map[string]string{
+		"module.registry":         "Unsupported module source",
+		"module.outside":          "Unsupported module source",
+		"module.app.module.again": "Recursive module call",
+	}
+	for _, diag := range plan.Diagnostics {
+		if want[diag.Address] != diag.Summary || diag.Severity != types.SeverityWarning || diag.Range == nil {
+			continue
+		}
+		delete(want, diag.Address)
+	}
+	if len(want) > 0 {
+		t.Errorf("missing warnings %v in %+v", want, plan.Diagnostics)
+	}
+}
+
+func TestModuleDirectoriesParsedOnce(t *testing.T) {
+	plan := loadFiles(t, map[string]string{
+		"main.tf": `
+module "a" {
+  source = "./web"
+}
+
+module "b" {
+  source = "./web"
+}
+`,
+		"web/main.tf": `
+resource "aws_instance" "web" {
+  instance_type = "t3.micro"
+}
+`,
+		"web/override.tf": `
+resource "aws_instance" "web" {
+  instance_type = "m5.large"
+}
+
+resource "aws_instance" "missing" {
+  instance_type = "m5.large"
+}
+`,
+		"web/broken.tf": `resource "aws_instance" {`,
+	})
+
+	for _, res := range plan.Resources {
+		if res.Config["instance_type"] != "m5.large" {
+			t.Errorf("%s: instance_type = %v, want the override", res.Address, res.Config["instance_type"])
+		}
+	}
+
+	count := make(map[string]int)
+	for _, diag := range plan.Diagnostics {
+		count[diag.Summary]++
+	}
+	if n := count["Missing base resource definition to override"]; n != 1 {
+		t.Errorf("override diagnostic reported %d times, want once", n)
+	}
+	if len(count) < 2 {
+		t.Errorf("missing parse error of web/broken.tf in %+v", plan.Diagnostics)
+	}
+}
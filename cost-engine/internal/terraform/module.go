@@ -0,0 +1,191 @@
+package terraform
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// maxModuleDepth bounds module nesting to guard against runaway recursion
+const maxModuleDepth = 16
+
+// moduleMetaArgs are module block arguments that are not passed as inputs
+var moduleMetaArgs = map[string]bool{
+	"source":     true,
+	"version":    true,
+	"count":      true,
+	"for_each":   true,
+	"providers":  true,
+	"depends_on": true,
+}
+
+// localModuleSource returns the source of a module block if it is a local path
+func localModuleSource(block *configBlock) (string, bool) {
+	attr, ok := block.Body.Attributes["source"]
+	if !ok {
+		return "", false
+	}
+
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || !val.IsKnown() || val.IsNull() || val.Type() != cty.String {
+		return "", false
+	}
+
+	source := val.AsString()
+	if !strings.HasPrefix(source, "./") && !strings.HasPrefix(source, "../") {
+		return "", false
+	}
+	return source, true
+}
+
+// parseModule evaluates a module call by loading its local source directory
+// with the caller's arguments bound to the module's variables
+func (l *Loader) parseModule(block *configBlock, plan *types.TerraformPlan) {
+	if len(block.Labels) == 0 {
+		return
+	}
+
+	name := block.Labels[0]
+	address := l.address("module." + name)
+	plan.Modules = append(plan.Modules, address)
+
+	// Outputs of modules that are not evaluated are unknown
+	l.modules[name] = cty.DynamicVal
+
+	// Calls that cannot be evaluated are reported and skipped
+	skip := func(summary, detail string) {
+		l.addDiagnostic(plan, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  summary,
+			Detail:   detail,
+			Subject:  &block.DefRange,
+		}, address)
+	}
+
+	source, ok := localModuleSource(block)
+	if !ok {
+		skip("Unsupported module source", "Only local module sources are supported; the module's resources are not estimated.")
+		return
+	}
+
+	callerDir := filepath.Dir(block.DefRange.Filename)
+	dir := filepath.Clean(filepath.Join(callerDir, source))
+	if dir != l.rootDir && !strings.HasPrefix(dir, l.rootDir+string(filepath.Separator)) {
+		skip("Unsupported module source", fmt.Sprintf("Source %q is outside the configuration; the module's resources are not estimated.", source))
+		return
+	}
+	for _, d := range l.callStack {
+		if d == dir {
+			skip("Recursive module call", fmt.Sprintf("Source %q calls a module that is already being evaluated.", source))
+			return
+		}
+	}
+	if len(l.callStack) >= maxModuleDepth {
+		skip("Module nesting too deep", fmt.Sprintf("Modules are nested more than %d levels.", maxModuleDepth))
+		return
+	}
+
+	cfg, ok := l.configs[dir]
+	if !ok {
+		skip("Failed to load module", fmt.Sprintf("Source %q holds no configuration files.", source))
+		return
+	}
+
+	providers := l.childProviders(block)
+
+	// Module arguments carry the required inputs they reference into the child
+	sources := make(map[string][]string)
+	for attrName, attr := range block.Body.Attributes {
+		if !moduleMetaArgs[attrName] {
+			sources["var."+attrName] = l.referenceSources(attr.Expr)
+		}
+	}
+
+	instances, forEachKeys, diags := l.expandInstances(block.Body)
+	l.addDiagnostics(plan, diags, address)
+	_, hasCount := block.Body.Attributes["count"]
+	if len(instances) == 0 && !diags.HasErrors() {
+		l.addDisabled(block.Body, address, "module", name, plan)
+	}
+
+	// A module without count/for_each is a single object; otherwise its
+	// instances are a tuple (count) or an object keyed by for_each key
+	var outputs []cty.Value
+	for _, inst := range instances {
+		// Evaluate the caller's arguments in the instance scope
+		inputs := make(map[string]cty.Value)
+		for attrName, attr := range block.Body.Attributes {
+			if moduleMetaArgs[attrName] {
+				continue
+			}
+			val, diags := attr.Expr.Value(inst.ctx)
+			l.addDiagnostics(plan, diags, address+inst.suffix)
+			inputs[attrName] = val
+		}
+
+		outputs = append(outputs, l.evaluateModule(address+inst.suffix, dir, cfg, inputs, providers, sources, plan))
+	}
+
+	switch {
+	case len(outputs) == 0 && !hasCount:
+		l.modules[name] = cty.EmptyObjectVal
+	case forEachKeys != nil:
+		byKey := make(map[string]cty.Value, len(forEachKeys))
+		for i, key := range forEachKeys {
+			byKey[key] = outputs[i]
+		}
+		l.modules[name] = cty.ObjectVal(byKey)
+	case hasCount && len(outputs) == 0:
+		l.modules[name] = cty.EmptyTupleVal
+	case hasCount && instances[0].suffix != "":
+		l.modules[name] = cty.TupleVal(outputs)
+	default:
+		l.modules[name] = outputs[0]
+	}
+}
+
+// evaluateModule evaluates one module instance in a child loader and
+// returns its outputs as an object value
+func (l *Loader) evaluateModule(address, dir string, cfg *moduleConfig, inputs map[string]cty.Value, providers map[string]string, sources map[string][]string, plan *types.TerraformPlan) cty.Value {
+	// Each instance gets its own copy, since provider blocks inside the
+	// module may add configurations
+	childProviders := make(map[string]string, len(providers))
+	for key, region := range providers {
+		childProviders[key] = region
+	}
+
+	inputSources := make(map[string][]string, len(sources))
+	for key, s := range sources {
+		inputSources[key] = s
+	}
+
+	child := &Loader{
+		parser:     l.parser,
+		variables:  make(map[string]cty.Value),
+		locals:     make(map[string]cty.Value),
+		modules:    make(map[string]cty.Value),
+		outputs:    make(map[string]cty.Value),
+		providers:  childProviders,
+		data:       make(map[string]map[string]cty.Value),
+		platforms:  make(map[string]*types.Platform),
+		resolvers:  l.resolvers,
+		metadata:   l.metadata,
+		configs:    l.configs,
+		inputs:     inputs,
+		rootDir:    l.rootDir,
+		dir:        dir,
+		modulePath: address,
+		callStack:  append(append([]string{}, l.callStack...), dir),
+
+		required:     l.required,
+		inputSources: inputSources,
+	}
+	child.evaluate(cfg, plan)
+
+	return cty.ObjectVal(child.outputs)
+}
@@ -0,0 +1,141 @@
+package terraform
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// isOverrideFile reports whether a file is an override file: override.tf,
+// *_override.tf or their .tf.json forms
+func isOverrideFile(path string) bool {
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".json"), ".tf")
+	return name == "override" || strings.HasSuffix(name, "_override")
+}
+
+// applyOverrides merges the blocks of a module's override files into the
+// blocks they override, following Terraform's override rules: each argument
+// in an override replaces the original argument, and each nested block type
+// in an override replaces all original blocks of that type. Overrides are
+// applied in file order and may not introduce new blocks.
+func (cfg *moduleConfig) applyOverrides(over *moduleConfig) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	diags = append(diags, overrideBlocks(cfg.variables, over.variables, "variable")...)
+	diags = append(diags, overrideBlocks(cfg.resources, over.resources, "resource")...)
+	diags = append(diags, overrideBlocks(cfg.dataSources, over.dataSources, "data")...)
+	diags = append(diags, overrideBlocks(cfg.outputs, over.outputs, "output")...)
+	diags = append(diags, overrideBlocks(cfg.moduleCalls, over.moduleCalls, "module")...)
+	diags = append(diags, overrideBlocks(cfg.providers, over.providers, "provider")...)
+	diags = append(diags, overrideLocals(cfg.locals, over.locals)...)
+	return diags
+}
+
+// overrideBlocks merges each override block into the original block with
+// the same labels (or provider configuration key)
+func overrideBlocks(base, overrides []*configBlock, kind string) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, over := range overrides {
+		key := overrideKey(over)
+
+		var target *configBlock
+		for _, block := range base {
+			if overrideKey(block) == key {
+				target = block
+				break
+			}
+		}
+		if target == nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Missing base %s definition to override", kind),
+				Detail:   fmt.Sprintf("There is no %s %q defined in a non-override file, so this override is ignored.", kind, key),
+				Subject:  over.DefRange.Ptr(),
+			})
+			continue
+		}
+
+		mergeBody(target.Body, over.Body)
+	}
+	return diags
+}
+
+// overrideKey identifies the block an override block applies to
+func overrideKey(block *configBlock) string {
+	if block.Type == "provider" {
+		return providerKey(block)
+	}
+	return strings.Join(block.Labels, ".")
+}
+
+// overrideLocals replaces local values by name, wherever they were defined
+func overrideLocals(base, overrides []*configBlock) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, over := range overrides {
+		for name, attr := range over.Body.Attributes {
+			found := false
+			for _, block := range base {
+				if _, ok := block.Body.Attributes[name]; ok {
+					block.Body.Attributes[name] = attr
+					found = true
+					break
+				}
+			}
+			if !found {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Missing base local value definition to override",
+					Detail:   fmt.Sprintf("There is no local value %q defined in a non-override file, so this override is ignored.", name),
+					Subject:  attr.Range.Ptr(),
+				})
+			}
+		}
+	}
+	return diags
+}
+
+// mergeBody applies an override body to an original body
+func mergeBody(base, over *configBody) {
+	// A nested block type may be written as an object attribute in JSON
+	// syntax, so attributes and blocks of the same name replace each other
+	replaced := make(map[string]bool)
+	for name := range over.Attributes {
+		replaced[name] = true
+	}
+	for _, block := range over.Blocks {
+		replaced[nestedBlockType(block)] = true
+	}
+
+	var kept []*configBlock
+	for _, block := range base.Blocks {
+		if !replaced[nestedBlockType(block)] {
+			kept = append(kept, block)
+		}
+	}
+	base.Blocks = append(kept, over.Blocks...)
+
+	for _, block := range over.Blocks {
+		delete(base.Attributes, nestedBlockType(block))
+	}
+	for name, attr := range over.Attributes {
+		base.Attributes[name] = attr
+	}
+
+	// count and for_each are mutually exclusive, so setting one drops the other
+	if _, ok := over.Attributes["count"]; ok {
+		delete(base.Attributes, "for_each")
+	}
+	if _, ok := over.Attributes["for_each"]; ok {
+		delete(base.Attributes, "count")
+	}
+}
+
+// nestedBlockType returns the type of block a nested block produces,
+// looking through dynamic blocks to the type they generate
+func nestedBlockType(block *configBlock) string {
+	if block.Type == "dynamic" && len(block.Labels) > 0 {
+		return block.Labels[0]
+	}
+	return block.Type
+}
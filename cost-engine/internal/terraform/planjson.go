@@ -0,0 +1,308 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// planJSON is the subset of `terraform show -json <planfile>` output we read
+type planJSON struct {
+	FormatVersion   string                  `json:"format_version"`
+	Variables       map[string]planVariable `json:"variables"`
+	PlannedValues   *planValues             `json:"planned_values"`
+	ResourceChanges []planResourceChange    `json:"resource_changes"`
+	Configuration   *planConfiguration      `json:"configuration"`
+}
+
+type planVariable struct {
+	Value interface{} `json:"value"`
+}
+
+type planValues struct {
+	Outputs    map[string]planOutput `json:"outputs"`
+	RootModule planModule            `json:"root_module"`
+}
+
+type planOutput struct {
+	Sensitive bool        `json:"sensitive"`
+	Value     interface{} `json:"value"`
+}
+
+type planModule struct {
+	Address      string         `json:"address"`
+	Resources    []planResource `json:"resources"`
+	ChildModules []planModule   `json:"child_modules"`
+}
+
+type planResource struct {
+	Address      string                 `json:"address"`
+	Mode         string                 `json:"mode"`
+	Type         string                 `json:"type"`
+	Name         string                 `json:"name"`
+	ProviderName string                 `json:"provider_name"`
+	Values       map[string]interface{} `json:"values"`
+	DependsOn    []string               `json:"depends_on"`
+}
+
+type planResourceChange struct {
+	Address       string `json:"address"`
+	ModuleAddress string `json:"module_address"`
+	Mode          string `json:"mode"`
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	ProviderName  string `json:"provider_name"`
+	Change        struct {
+		Actions []string               `json:"actions"`
+		After   map[string]interface{} `json:"after"`
+	} `json:"change"`
+}
+
+// planConfiguration is the configuration a plan was made from, which
+// records the provider configuration each resource uses
+type planConfiguration struct {
+	ProviderConfig map[string]planProviderConfig `json:"provider_config"`
+	RootModule     planConfigModule              `json:"root_module"`
+}
+
+type planProviderConfig struct {
+	Name          string                    `json:"name"`
+	ModuleAddress string                    `json:"module_address"`
+	Expressions   map[string]planExpression `json:"expressions"`
+}
+
+type planExpression struct {
+	ConstantValue interface{} `json:"constant_value"`
+	References    []string    `json:"references"`
+}
+
+type planConfigModule struct {
+	Resources   []planConfigResource      `json:"resources"`
+	ModuleCalls map[string]planModuleCall `json:"module_calls"`
+}
+
+type planConfigResource struct {
+	Address           string `json:"address"`
+	ProviderConfigKey string `json:"provider_config_key"`
+}
+
+type planModuleCall struct {
+	Module planConfigModule `json:"module"`
+}
+
+// ParsePlanJSON converts `terraform show -json` plan output into a plan.
+// Values in plan JSON are fully resolved by Terraform, so modules, count,
+// for_each and data sources need no evaluation here.
+func ParsePlanJSON(data []byte) (*types.TerraformPlan, error) {
+	var raw planJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid plan JSON: %w", err)
+	}
+	if raw.PlannedValues == nil && raw.ResourceChanges == nil {
+		return nil, fmt.Errorf("not a Terraform plan: missing planned_values and resource_changes")
+	}
+
+	plan := &types.TerraformPlan{
+		Resources:   []types.TerraformResource{},
+		Variables:   make(map[string]interface{}),
+		Locals:      make(map[string]interface{}),
+		DataSources: []types.TerraformResource{},
+		Outputs:     make(map[string]interface{}),
+		Modules:     []string{},
+	}
+
+	for name, v := range raw.Variables {
+		plan.Variables[name] = v.Value
+	}
+	regions := raw.resourceRegions()
+
+	if raw.PlannedValues != nil {
+		// planned_values describes the state after apply, so deleted
+		// resources are already excluded
+		for name, out := range raw.PlannedValues.Outputs {
+			if !out.Sensitive {
+				plan.Outputs[name] = out.Value
+			}
+		}
+		addPlanModule(plan, raw.PlannedValues.RootModule, regions)
+		return plan, nil
+	}
+
+	// Older or filtered output may only carry resource_changes
+	modules := make(map[string]bool)
+	for _, rc := range raw.ResourceChanges {
+		if isDeleteOnly(rc.Change.Actions) || rc.Change.After == nil {
+			continue
+		}
+		if rc.ModuleAddress != "" && !modules[rc.ModuleAddress] {
+			modules[rc.ModuleAddress] = true
+			plan.Modules = append(plan.Modules, rc.ModuleAddress)
+		}
+		addPlanResource(plan, planResource{
+			Address:      rc.Address,
+			Mode:         rc.Mode,
+			Type:         rc.Type,
+			Name:         rc.Name,
+			ProviderName: rc.ProviderName,
+			Values:       rc.Change.After,
+		}, rc.ModuleAddress, regions)
+	}
+
+	return plan, nil
+}
+
+// resourceRegions returns the region of the provider configuration each
+// configured resource uses, keyed by its address without instance keys,
+// e.g. module.app.aws_instance.web. Regions are read from constant values
+// and root module variables; anything else is left to the request region.
+func (raw *planJSON) resourceRegions() map[string]string {
+	regions := make(map[string]string)
+	if raw.Configuration == nil {
+		return regions
+	}
+
+	var walk func(module planConfigModule, prefix string)
+	walk = func(module planConfigModule, prefix string) {
+		for _, res := range module.Resources {
+			if region := raw.providerRegion(res.ProviderConfigKey); region != "" {
+				regions[prefix+res.Address] = region
+			}
+		}
+		for name, call := range module.ModuleCalls {
+			walk(call.Module, prefix+"module."+name+".")
+		}
+	}
+	walk(raw.Configuration.RootModule, "")
+	return regions
+}
+
+// providerRegion returns the region of a provider_config entry, such as
+// aws, aws.west or module.app:aws
+func (raw *planJSON) providerRegion(key string) string {
+	cfg, ok := raw.Configuration.ProviderConfig[key]
+	if !ok && strings.Contains(key, ":") && !strings.HasPrefix(key, "module.") {
+		// Older Terraform versions omit the module. prefix
+		cfg, ok = raw.Configuration.ProviderConfig["module."+key]
+	}
+	if !ok {
+		return ""
+	}
+
+	expr := cfg.Expressions["region"]
+	if region, ok := expr.ConstantValue.(string); ok {
+		return region
+	}
+	if cfg.ModuleAddress == "" && len(expr.References) > 0 {
+		if name, ok := strings.CutPrefix(expr.References[0], "var."); ok {
+			if region, ok := raw.Variables[name].Value.(string); ok {
+				return region
+			}
+		}
+	}
+	return ""
+}
+
+// configAddress returns the configuration address of a resource instance
+// address by dropping instance keys, e.g. module.app["a"].aws_instance.web[0]
+// becomes module.app.aws_instance.web
+func configAddress(address string) string {
+	var b strings.Builder
+	depth := 0
+	inString := false
+	for i := 0; i < len(address); i++ {
+		c := address[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth > 0:
+			if c == '"' {
+				inString = true
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// addPlanModule adds the resources of a planned module and its children
+func addPlanModule(plan *types.TerraformPlan, module planModule, regions map[string]string) {
+	if module.Address != "" {
+		plan.Modules = append(plan.Modules, module.Address)
+	}
+	for _, res := range module.Resources {
+		addPlanResource(plan, res, module.Address, regions)
+	}
+	for _, child := range module.ChildModules {
+		addPlanModule(plan, child, regions)
+	}
+}
+
+// addPlanResource converts a plan resource into a TerraformResource, in
+// the region of its provider configuration when the plan records one
+func addPlanResource(plan *types.TerraformPlan, res planResource, module string, regions map[string]string) {
+	resource := types.TerraformResource{
+		Type:      res.Type,
+		Name:      res.Name,
+		Address:   res.Address,
+		Provider:  providerLocalName(res.ProviderName, res.Type),
+		Config:    normalizeJSONConfig(res.Values),
+		Count:     1,
+		DependsOn: res.DependsOn,
+		Module:    module,
+		Region:    regions[configAddress(res.Address)],
+	}
+
+	if res.Mode == "data" {
+		plan.DataSources = append(plan.DataSources, resource)
+		return
+	}
+	plan.Resources = append(plan.Resources, resource)
+}
+
+// isDeleteOnly reports whether a change removes the resource without replacing it
+func isDeleteOnly(actions []string) bool {
+	return len(actions) == 1 && actions[0] == "delete"
+}
+
+// providerLocalName returns the short provider name (e.g. aws) from a
+// fully qualified provider address, falling back to the resource type prefix
+func providerLocalName(providerName, resourceType string) string {
+	if providerName != "" {
+		parts := strings.Split(providerName, "/")
+		return parts[len(parts)-1]
+	}
+	parts := strings.Split(resourceType, "_")
+	return parts[0]
+}
+
+// normalizeJSONConfig reshapes attribute values decoded from Terraform JSON
+// to match what the HCL loader produces: single nested blocks, which JSON
+// represents as one-element lists, become plain maps and null attributes
+// are dropped
+func normalizeJSONConfig(values map[string]interface{}) map[string]interface{} {
+	config := make(map[string]interface{}, len(values))
+	for key, val := range values {
+		if val == nil {
+			continue
+		}
+		if list, ok := val.([]interface{}); ok && len(list) == 1 {
+			if block, ok := list[0].(map[string]interface{}); ok {
+				config[key] = normalizeJSONConfig(block)
+				continue
+			}
+		}
+		config[key] = val
+	}
+	return config
+}
@@ -0,0 +1,83 @@
+package terraform
+
+import "testing"
+
+func TestParsePlanJSONRegions(t *testing.T) {
+	data := []byte(`{
+  "format_version": "1.2",
+  "variables": {"region": {"value": "eu-west-1"}},
+  "planned_values": {
+    "root_module": {
+      "resources": [
+        {"address": "aws_instance.default", "mode": "managed", "type": "aws_instance", "name": "default", "values": {}},
+        {"address": "aws_instance.west[0]", "mode": "managed", "type": "aws_instance", "name": "west", "values": {}}
+      ],
+      "child_modules": [
+        {
+          "address": "module.app[\"blue\"]",
+          "resources": [
+            {"address": "module.app[\"blue\"].aws_instance.web", "mode": "managed", "type": "aws_instance", "name": "web", "values": {}}
+          ]
+        },
+        {
+          "address": "module.legacy",
+          "resources": [
+            {"address": "module.legacy.aws_instance.old", "mode": "managed", "type": "aws_instance", "name": "old", "values": {}}
+          ]
+        }
+      ]
+    }
+  },
+  "configuration": {
+    "provider_config": {
+      "aws": {"name": "aws", "expressions": {"region": {"references": ["var.region"]}}},
+      "aws.west": {"name": "aws", "alias": "west", "expressions": {"region": {"constant_value": "us-west-2"}}},
+      "module.legacy:aws": {"name": "aws", "module_address": "module.legacy", "expressions": {"region": {"references": ["var.region"]}}}
+    },
+    "root_module": {
+      "resources": [
+        {"address": "aws_instance.default", "provider_config_key": "aws"},
+        {"address": "aws_instance.west", "provider_config_key": "aws.west"}
+      ],
+      "module_calls": {
+        "app": {"module": {"resources": [{"address": "aws_instance.web", "provider_config_key": "aws.west"}]}},
+        "legacy": {"module": {"resources": [{"address": "aws_instance.old", "provider_config_key": "legacy:aws"}]}}
+      }
+    }
+  }
+}`)
+
+	plan, err := ParsePlanJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"aws_instance.default":                "eu-west-1",
+		"aws_instance.west[0]":                "us-west-2",
+		`module.app["blue"].aws_instance.web`: "us-west-2",
+		"module.legacy.aws_instance.old":      "", // Module variables are not in the plan
+	}
+	if len(plan.Resources) != len(want) {
+		t.Fatalf("got %d resources, want %d", len(plan.Resources), len(want))
+	}
+	for _, res := range plan.Resources {
+		if region, ok := want[res.Address]; !ok || res.Region != region {
+			t.Errorf("%s: region = %q, want %q", res.Address, res.Region, region)
+		}
+	}
+}
+
+func TestConfigAddress(t *testing.T) {
+	tests := map[string]string{
+		"aws_instance.web":                                "aws_instance.web",
+		"aws_instance.web[3]":                             "aws_instance.web",
+		`module.app["a.b"].aws_instance.web["x"]`:         "module.app.aws_instance.web",
+		`module.a[0].module.b["k]\"y"].data.aws_ami.this`: "module.a.module.b.data.aws_ami.this",
+	}
+	for address, want := range tests {
+		if got := configAddress(address); got != want {
+			t.Errorf("configAddress(%q) = %q, want %q", address, got, want)
+		}
+	}
+}
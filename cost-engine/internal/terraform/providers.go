@@ -0,0 +1,108 @@
+package terraform
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// providerKey returns the configuration key of a provider block, e.g.
+// aws or aws.us_west for an aliased configuration
+func providerKey(block *configBlock) string {
+	if len(block.Labels) == 0 {
+		return ""
+	}
+	key := block.Labels[0]
+	if attr, ok := block.Body.Attributes["alias"]; ok {
+		val, diags := attr.Expr.Value(nil)
+		if !diags.HasErrors() && val.IsKnown() && !val.IsNull() && val.Type() == cty.String {
+			key += "." + val.AsString()
+		}
+	}
+	return key
+}
+
+// parseProvider records the region of a provider configuration
+func (l *Loader) parseProvider(block *configBlock, plan *types.TerraformPlan) {
+	key := providerKey(block)
+	if key == "" {
+		return
+	}
+
+	region := ""
+	if attr, ok := block.Body.Attributes["region"]; ok {
+		val, diags := attr.Expr.Value(l.evalCtx)
+		l.addDiagnostics(plan, diags, "provider."+key)
+		if val.IsKnown() && !val.IsNull() && val.Type() == cty.String {
+			region = val.AsString()
+		}
+	}
+	l.providers[key] = region
+}
+
+// providerReference returns the configuration key named by a provider
+// reference expression such as aws.us_west
+func providerReference(expr hcl.Expression) string {
+	traversal, diags := hcl.AbsTraversalForExpr(expr)
+	if diags.HasErrors() || len(traversal) == 0 {
+		// Quoted keys in a providers map, e.g. "aws.dr" = aws.dr
+		if val, diags := expr.Value(nil); !diags.HasErrors() && val.IsKnown() && !val.IsNull() && val.Type() == cty.String {
+			return val.AsString()
+		}
+		return ""
+	}
+
+	parts := []string{traversal.RootName()}
+	for _, step := range traversal[1:] {
+		if attr, ok := step.(hcl.TraverseAttr); ok {
+			parts = append(parts, attr.Name)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// resourceRegion returns the region of the provider configuration a
+// resource uses: its provider argument if set, otherwise the default
+// configuration for its provider
+func (l *Loader) resourceRegion(body *configBody, provider string) string {
+	key := provider
+	if attr, ok := body.Attributes["provider"]; ok {
+		if ref := providerReference(attr.Expr); ref != "" {
+			key = ref
+		}
+	}
+	return l.providers[key]
+}
+
+// childProviders returns the provider regions visible to a module call.
+// Default configurations are inherited; a providers map passes others in
+// under the child's own names.
+func (l *Loader) childProviders(block *configBlock) map[string]string {
+	providers := make(map[string]string)
+	for key, region := range l.providers {
+		if !strings.Contains(key, ".") {
+			providers[key] = region
+		}
+	}
+
+	attr, ok := block.Body.Attributes["providers"]
+	if !ok {
+		return providers
+	}
+	pairs, diags := hcl.ExprMap(attr.Expr)
+	if diags.HasErrors() {
+		return providers
+	}
+	for _, pair := range pairs {
+		childKey := providerReference(pair.Key)
+		parentKey := providerReference(pair.Value)
+		if childKey == "" || parentKey == "" {
+			continue
+		}
+		providers[childKey] = l.providers[parentKey]
+	}
+	return providers
+}
@@ -0,0 +1,190 @@
+package terraform
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// recordReferences records the resources and data sources a resource
+// instance's attributes refer to, e.g. instance = aws_instance.web.id.
+// Targets are resolved to instance addresses by resolveReferences once the
+// whole configuration has been evaluated.
+func (l *Loader) recordReferences(body *configBody, from string, ctx *hcl.EvalContext, prefix string, plan *types.TerraformPlan) {
+	for name, attr := range body.Attributes {
+		if name == "depends_on" {
+			// Explicit dependencies are kept in DependsOn
+			continue
+		}
+		for _, target := range referenceTargets(attr.Expr, ctx) {
+			plan.References = append(plan.References, types.ResourceReference{
+				From:      from,
+				To:        l.address(target),
+				Attribute: prefix + name,
+			})
+		}
+	}
+	for _, block := range body.Blocks {
+		if block.Type == "lifecycle" {
+			continue
+		}
+		nested := prefix + nestedBlockType(block) + "."
+		if block.Type == "content" {
+			nested = prefix
+		}
+		l.recordReferences(block.Body, from, ctx, nested, plan)
+	}
+}
+
+// referenceTargets returns the module-relative addresses of the resources
+// and data sources an expression refers to. An index selecting one
+// instance, such as aws_instance.web[count.index], is evaluated in ctx so
+// the target is that instance; otherwise the target is the whole resource.
+func referenceTargets(expr hcl.Expression, ctx *hcl.EvalContext) []string {
+	var targets []string
+	seen := make(map[string]bool)
+	add := func(target string) {
+		if target != "" && !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+
+	node, ok := expr.(hclsyntax.Node)
+	if !ok {
+		// JSON syntax expressions only expose their traversals
+		for _, traversal := range expr.Variables() {
+			add(resourceTarget(traversal))
+		}
+		return targets
+	}
+
+	indexed := make(map[*hclsyntax.ScopeTraversalExpr]bool)
+	hclsyntax.VisitAll(node, func(n hclsyntax.Node) hcl.Diagnostics {
+		switch e := n.(type) {
+		case *hclsyntax.IndexExpr:
+			coll, ok := e.Collection.(*hclsyntax.ScopeTraversalExpr)
+			if !ok || !isResourceTraversal(coll.Traversal) {
+				break
+			}
+			key, diags := e.Key.Value(ctx)
+			if suffix, ok := indexSuffix(key); ok && !diags.HasErrors() {
+				add(resourceTarget(coll.Traversal) + suffix)
+				indexed[coll] = true
+			}
+		case *hclsyntax.ScopeTraversalExpr:
+			if !indexed[e] {
+				add(resourceTarget(e.Traversal))
+			}
+		}
+		return nil
+	})
+	return targets
+}
+
+// resourceTarget returns the address a traversal refers to when it is a
+// resource (aws_instance.web) or data source (data.aws_ami.ubuntu)
+// reference, including a literal instance index, or an empty string
+func resourceTarget(traversal hcl.Traversal) string {
+	parts := 2
+	if traversal.RootName() == "data" {
+		parts = 3
+	}
+	if !isResourceTraversal(traversal) || len(traversal) < parts {
+		return ""
+	}
+
+	names := []string{traversal.RootName()}
+	for _, step := range traversal[1:parts] {
+		attr, ok := step.(hcl.TraverseAttr)
+		if !ok {
+			return ""
+		}
+		names = append(names, attr.Name)
+	}
+	target := strings.Join(names, ".")
+
+	if len(traversal) > parts {
+		if index, ok := traversal[parts].(hcl.TraverseIndex); ok {
+			if suffix, ok := indexSuffix(index.Key); ok {
+				target += suffix
+			}
+		}
+	}
+	return target
+}
+
+// isResourceTraversal reports whether a traversal starts at a resource type
+// or data source rather than a variable, local, module or iterator
+func isResourceTraversal(traversal hcl.Traversal) bool {
+	root := traversal.RootName()
+	return root == "data" || strings.Contains(root, "_")
+}
+
+// indexSuffix renders a known count index or for_each key as an address
+// suffix, e.g. [0] or ["api"]
+func indexSuffix(key cty.Value) (string, bool) {
+	if !key.IsKnown() || key.IsNull() {
+		return "", false
+	}
+	switch key.Type() {
+	case cty.Number:
+		if i, acc := key.AsBigFloat().Int64(); acc == big.Exact {
+			return fmt.Sprintf("[%d]", i), true
+		}
+	case cty.String:
+		return fmt.Sprintf("[%q]", key.AsString()), true
+	}
+	return "", false
+}
+
+// resolveReferences resolves recorded reference targets to the instances
+// in the plan. A reference to a whole resource becomes one reference per
+// instance; references to resources that were not created are dropped.
+func resolveReferences(plan *types.TerraformPlan) []types.ResourceReference {
+	instances := make(map[string][]string)
+	addInstance := func(r types.TerraformResource, kind string) {
+		base := kind + r.Type + "." + r.Name
+		if r.Module != "" {
+			base = r.Module + "." + base
+		}
+		instances[base] = append(instances[base], r.Address)
+		if r.Address != base {
+			instances[r.Address] = append(instances[r.Address], r.Address)
+		}
+	}
+	for _, r := range plan.Resources {
+		addInstance(r, "")
+	}
+	for _, r := range plan.DataSources {
+		addInstance(r, "data.")
+	}
+
+	refs := []types.ResourceReference{}
+	seen := make(map[types.ResourceReference]bool)
+	for _, ref := range plan.References {
+		for _, to := range instances[ref.To] {
+			resolved := types.ResourceReference{From: ref.From, To: to, Attribute: ref.Attribute}
+			if resolved.From == resolved.To || seen[resolved] {
+				continue
+			}
+			seen[resolved] = true
+			refs = append(refs, resolved)
+		}
+	}
+
+	sort.SliceStable(refs, func(i, j int) bool {
+		if refs[i].From != refs[j].From {
+			return refs[i].From < refs[j].From
+		}
+		return refs[i].Attribute < refs[j].Attribute
+	})
+	return refs
+}
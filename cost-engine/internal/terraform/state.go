@@ -0,0 +1,144 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// stateJSON is the subset of a version 4 terraform.tfstate file we read
+type stateJSON struct {
+	Version   int                    `json:"version"`
+	Outputs   map[string]stateOutput `json:"outputs"`
+	Resources []stateResource        `json:"resources"`
+}
+
+type stateOutput struct {
+	Value     interface{} `json:"value"`
+	Sensitive bool        `json:"sensitive"`
+}
+
+type stateResource struct {
+	Module    string          `json:"module"`
+	Mode      string          `json:"mode"`
+	Type      string          `json:"type"`
+	Name      string          `json:"name"`
+	Provider  string          `json:"provider"`
+	Instances []stateInstance `json:"instances"`
+}
+
+type stateInstance struct {
+	IndexKey     interface{}            `json:"index_key"`
+	Deposed      string                 `json:"deposed"`
+	Attributes   map[string]interface{} `json:"attributes"`
+	Dependencies []string               `json:"dependencies"`
+}
+
+// ParseState converts a version 4 terraform.tfstate file into a plan
+// describing the resources that are currently deployed
+func ParseState(data []byte) (*types.TerraformPlan, error) {
+	var raw stateJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid state JSON: %w", err)
+	}
+	if raw.Version != 4 {
+		return nil, fmt.Errorf("unsupported state version %d (only version 4 is supported)", raw.Version)
+	}
+
+	plan := &types.TerraformPlan{
+		Resources:   []types.TerraformResource{},
+		Variables:   make(map[string]interface{}),
+		Locals:      make(map[string]interface{}),
+		DataSources: []types.TerraformResource{},
+		Outputs:     make(map[string]interface{}),
+		Modules:     []string{},
+	}
+
+	for name, out := range raw.Outputs {
+		if !out.Sensitive {
+			plan.Outputs[name] = out.Value
+		}
+	}
+
+	modules := make(map[string]bool)
+	for _, res := range raw.Resources {
+		if res.Module != "" && !modules[res.Module] {
+			modules[res.Module] = true
+			plan.Modules = append(plan.Modules, res.Module)
+		}
+
+		base := fmt.Sprintf("%s.%s", res.Type, res.Name)
+		if res.Mode == "data" {
+			base = "data." + base
+		}
+		if res.Module != "" {
+			base = res.Module + "." + base
+		}
+
+		for _, inst := range res.Instances {
+			// Deposed objects are pending destruction after a replace
+			if inst.Deposed != "" {
+				continue
+			}
+
+			resource := types.TerraformResource{
+				Type:      res.Type,
+				Name:      res.Name,
+				Address:   base + indexKeySuffix(inst.IndexKey),
+				Provider:  stateProviderName(res.Provider, res.Type),
+				Region:    arnRegion(inst.Attributes["arn"]),
+				Config:    normalizeJSONConfig(inst.Attributes),
+				Count:     1,
+				DependsOn: inst.Dependencies,
+				Module:    res.Module,
+			}
+
+			if res.Mode == "data" {
+				plan.DataSources = append(plan.DataSources, resource)
+			} else {
+				plan.Resources = append(plan.Resources, resource)
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// indexKeySuffix renders a state index_key as an address suffix
+func indexKeySuffix(key interface{}) string {
+	switch k := key.(type) {
+	case float64:
+		return fmt.Sprintf("[%d]", int64(k))
+	case string:
+		return fmt.Sprintf("[%q]", k)
+	default:
+		return ""
+	}
+}
+
+// stateProviderName extracts the provider name from a state provider
+// reference like provider["registry.terraform.io/hashicorp/aws"].west
+func stateProviderName(provider, resourceType string) string {
+	start := strings.Index(provider, `["`)
+	end := strings.LastIndex(provider, `"]`)
+	if start < 0 || end <= start {
+		return providerLocalName("", resourceType)
+	}
+	return providerLocalName(provider[start+2:end], resourceType)
+}
+
+// arnRegion returns the region field of an ARN attribute
+// (arn:partition:service:region:account:resource), or "" for global ARNs
+func arnRegion(arn interface{}) string {
+	s, ok := arn.(string)
+	if !ok {
+		return ""
+	}
+	parts := strings.SplitN(s, ":", 5)
+	if len(parts) < 5 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[3]
+}
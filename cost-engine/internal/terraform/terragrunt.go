@@ -0,0 +1,694 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// terragruntFile is the name of a Terragrunt unit configuration
+const terragruntFile = "terragrunt.hcl"
+
+// maxIncludeDepth bounds include and read_terragrunt_config nesting
+const maxIncludeDepth = 8
+
+// terragruntConfig holds the parts of a Terragrunt configuration that affect
+// cost, with included configurations already merged in
+type terragruntConfig struct {
+	source       string               // terraform { source }, if set
+	inputs       map[string]cty.Value // inputs passed to the module
+	locals       map[string]cty.Value
+	dependencies map[string]string // dependency name -> unit directory
+	generated    map[string]string // generate block path -> contents
+}
+
+// merge applies an included configuration beneath cfg
+func (cfg *terragruntConfig) merge(parent *terragruntConfig) {
+	if parent.source != "" {
+		cfg.source = parent.source
+	}
+	for name, val := range parent.inputs {
+		cfg.inputs[name] = val
+	}
+	for name, dir := range parent.dependencies {
+		cfg.dependencies[name] = dir
+	}
+	for path, contents := range parent.generated {
+		cfg.generated[path] = contents
+	}
+}
+
+// isTerragrunt reports whether an upload is a Terragrunt configuration:
+// its top directory is a Terragrunt unit, or holds no Terraform files of its
+// own and includes units below it. A terragrunt.hcl inside a Terraform root
+// module, such as in an example directory, does not switch modes.
+func isTerragrunt(rootDir string, terragruntFiles []string, filesByDir map[string][]string) bool {
+	if len(terragruntFiles) == 0 {
+		return false
+	}
+
+	// Archives often wrap the configuration in a single directory
+	top := rootDir
+	for {
+		entries, err := os.ReadDir(top)
+		if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+			break
+		}
+		top = filepath.Join(top, entries[0].Name())
+	}
+
+	for _, path := range terragruntFiles {
+		if filepath.Dir(path) == top {
+			return true
+		}
+	}
+	return len(filesByDir[top]) == 0
+}
+
+// loadTerragrunt evaluates every Terragrunt unit in the upload and combines
+// them into one plan. Each unit is a separate stack: its addresses are
+// prefixed with the unit directory, e.g. live/prod/vpc//aws_nat_gateway.this.
+func (l *Loader) loadTerragrunt(files []string, opts LoadOptions, plan *types.TerraformPlan) (*types.TerraformPlan, error) {
+	rootDir := l.rootDir
+
+	// Read dependencies first so units are evaluated after the units whose
+	// outputs they consume
+	configs := make(map[string]*terragruntConfig)
+	var dirs []string
+	for _, path := range files {
+		dir := filepath.Dir(path)
+		cfg, diags := l.parseTerragrunt(path, dir, nil, 0)
+		if diags.HasErrors() {
+			l.addDiagnostics(plan, diags, "")
+			continue
+		}
+		configs[dir] = cfg
+		dirs = append(dirs, dir)
+	}
+
+	outputs := make(map[string]cty.Value)
+	for _, dir := range l.terragruntOrder(dirs, configs, plan) {
+		stack := filepath.ToSlash(strings.TrimPrefix(strings.TrimPrefix(dir, rootDir), string(filepath.Separator)))
+		if stack == "" {
+			stack = "."
+		}
+
+		// Evaluate again now that dependency outputs are known
+		cfg, diags := l.parseTerragrunt(filepath.Join(dir, terragruntFile), dir, outputs, 0)
+		l.addDiagnostics(plan, diags, stack)
+		if diags.HasErrors() {
+			continue
+		}
+
+		sourceDir, diag := l.terragruntSource(cfg, dir)
+		if diag != nil {
+			l.addDiagnostic(plan, diag, stack)
+			continue
+		}
+		if sourceDir == "" {
+			// A configuration without a module, such as a root terragrunt.hcl
+			// that units include
+			continue
+		}
+
+		unitPlan, unitOutputs, err := l.loadUnit(rootDir, sourceDir, dir, cfg, opts)
+		if err != nil {
+			l.addDiagnostic(plan, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to load Terragrunt unit",
+				Detail:   err.Error(),
+				Subject:  &hcl.Range{Filename: filepath.Join(dir, terragruntFile)},
+			}, stack)
+			continue
+		}
+		outputs[dir] = unitOutputs
+		addStack(plan, unitPlan, stack)
+	}
+
+	l.reset(rootDir, rootDir)
+	if len(plan.Resources) == 0 && len(plan.Diagnostics) > 0 {
+		d := plan.Diagnostics[0]
+		return nil, fmt.Errorf("failed to load Terragrunt configuration: %s: %s", d.Address, d.Summary)
+	}
+	return plan, nil
+}
+
+// terragruntOrder sorts unit directories so dependencies come first
+func (l *Loader) terragruntOrder(dirs []string, configs map[string]*terragruntConfig, plan *types.TerraformPlan) []string {
+	sort.Strings(dirs)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var order []string
+
+	var visit func(dir string)
+	visit = func(dir string) {
+		cfg, ok := configs[dir]
+		if !ok || state[dir] == done {
+			return
+		}
+		if state[dir] == visiting {
+			l.addDiagnostic(plan, &hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Cycle in Terragrunt dependencies",
+				Detail:   "Units depend on each other; mock outputs are used where the cycle is broken.",
+				Subject:  &hcl.Range{Filename: filepath.Join(dir, terragruntFile)},
+			}, "")
+			return
+		}
+
+		state[dir] = visiting
+		var deps []string
+		for _, dep := range cfg.dependencies {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			visit(dep)
+		}
+		state[dir] = done
+		order = append(order, dir)
+	}
+
+	for _, dir := range dirs {
+		visit(dir)
+	}
+	return order
+}
+
+// parseTerragrunt evaluates a Terragrunt configuration file for the unit in
+// unitDir. outputs holds the outputs of units already evaluated; nil means
+// dependencies resolve to their mock outputs.
+func (l *Loader) parseTerragrunt(path, unitDir string, outputs map[string]cty.Value, depth int) (*terragruntConfig, hcl.Diagnostics) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to read file",
+			Detail:   err.Error(),
+			Subject:  &hcl.Range{Filename: path},
+		}}
+	}
+
+	file, diags := l.parser.ParseHCL(src, path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, diags
+	}
+
+	fileDir := filepath.Dir(path)
+	cfg := &terragruntConfig{
+		inputs:       make(map[string]cty.Value),
+		locals:       make(map[string]cty.Value),
+		dependencies: make(map[string]string),
+		generated:    make(map[string]string),
+	}
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"local":      cty.EmptyObjectVal,
+			"include":    cty.EmptyObjectVal,
+			"dependency": cty.EmptyObjectVal,
+		},
+		Functions: l.terragruntFunctions(unitDir, fileDir, depth),
+	}
+
+	// Locals come first and may refer to each other in any order
+	var localAttrs []*hclsyntax.Attribute
+	for _, block := range body.Blocks {
+		if block.Type == "locals" {
+			for _, attr := range block.Body.Attributes {
+				localAttrs = append(localAttrs, attr)
+			}
+		}
+	}
+	diags = append(diags, evalTerragruntLocals(localAttrs, ctx, cfg.locals)...)
+
+	// Included configurations provide defaults for everything else
+	includes := make(map[string]cty.Value)
+	for _, block := range body.Blocks {
+		if block.Type != "include" {
+			continue
+		}
+		attr, ok := block.Body.Attributes["path"]
+		if !ok {
+			continue
+		}
+		val, valDiags := attr.Expr.Value(ctx)
+		diags = append(diags, valDiags...)
+		if valDiags.HasErrors() || !val.IsKnown() || val.IsNull() || val.Type() != cty.String {
+			continue
+		}
+		if depth >= maxIncludeDepth {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Include nesting too deep",
+				Detail:   fmt.Sprintf("Includes are nested more than %d levels.", maxIncludeDepth),
+				Subject:  attr.SrcRange.Ptr(),
+			})
+			continue
+		}
+
+		includePath := l.terragruntPath(fileDir, val.AsString())
+		if !l.withinRoot(includePath) {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Include outside the configuration",
+				Detail:   fmt.Sprintf("Included file %q is outside the configuration and is skipped.", val.AsString()),
+				Subject:  attr.SrcRange.Ptr(),
+			})
+			continue
+		}
+		parent, parentDiags := l.parseTerragrunt(includePath, unitDir, outputs, depth+1)
+		diags = append(diags, parentDiags...)
+		if parent == nil {
+			continue
+		}
+		cfg.merge(parent)
+		if len(block.Labels) > 0 {
+			includes[block.Labels[0]] = cty.ObjectVal(map[string]cty.Value{
+				"locals": cty.ObjectVal(parent.locals),
+				"inputs": cty.ObjectVal(parent.inputs),
+			})
+		}
+	}
+	ctx.Variables["include"] = cty.ObjectVal(includes)
+
+	// Dependencies expose the outputs of other units
+	deps := make(map[string]cty.Value)
+	for _, block := range body.Blocks {
+		if block.Type != "dependency" || len(block.Labels) == 0 {
+			continue
+		}
+		name := block.Labels[0]
+		var depOutputs cty.Value
+		found := false
+
+		if attr, ok := block.Body.Attributes["config_path"]; ok {
+			val, valDiags := attr.Expr.Value(ctx)
+			diags = append(diags, valDiags...)
+			if !valDiags.HasErrors() && val.IsKnown() && !val.IsNull() && val.Type() == cty.String {
+				dir := l.terragruntPath(unitDir, val.AsString())
+				cfg.dependencies[name] = dir
+				depOutputs, found = outputs[dir]
+			}
+		}
+		if !found {
+			depOutputs = cty.DynamicVal
+			if attr, ok := block.Body.Attributes["mock_outputs"]; ok {
+				val, valDiags := attr.Expr.Value(ctx)
+				diags = append(diags, valDiags...)
+				if !valDiags.HasErrors() {
+					depOutputs = val
+				}
+			}
+		}
+
+		deps[name] = cty.ObjectVal(map[string]cty.Value{"outputs": depOutputs})
+	}
+	ctx.Variables["dependency"] = cty.ObjectVal(deps)
+
+	for _, block := range body.Blocks {
+		switch block.Type {
+		case "terraform":
+			if attr, ok := block.Body.Attributes["source"]; ok {
+				val, valDiags := attr.Expr.Value(ctx)
+				diags = append(diags, valDiags...)
+				if !valDiags.HasErrors() && val.IsKnown() && !val.IsNull() && val.Type() == cty.String {
+					cfg.source = val.AsString()
+				}
+			}
+		case "generate":
+			pathAttr, hasPath := block.Body.Attributes["path"]
+			contentsAttr, hasContents := block.Body.Attributes["contents"]
+			if !hasPath || !hasContents {
+				continue
+			}
+			pathVal, pathDiags := pathAttr.Expr.Value(ctx)
+			contentsVal, contentsDiags := contentsAttr.Expr.Value(ctx)
+			diags = append(diags, pathDiags...)
+			diags = append(diags, contentsDiags...)
+			if pathVal.IsWhollyKnown() && contentsVal.IsWhollyKnown() && !pathVal.IsNull() && !contentsVal.IsNull() &&
+				pathVal.Type() == cty.String && contentsVal.Type() == cty.String {
+				cfg.generated[pathVal.AsString()] = contentsVal.AsString()
+			}
+		}
+	}
+
+	// Inputs override those of included configurations
+	if attr, ok := body.Attributes["inputs"]; ok {
+		val, valDiags := attr.Expr.Value(ctx)
+		diags = append(diags, valDiags...)
+		if val.IsKnown() && !val.IsNull() && (val.Type().IsObjectType() || val.Type().IsMapType()) {
+			for it := val.ElementIterator(); it.Next(); {
+				k, v := it.Element()
+				cfg.inputs[k.AsString()] = v
+			}
+		}
+	}
+
+	return cfg, diags
+}
+
+// evalTerragruntLocals evaluates locals in dependency order by retrying
+// those that refer to locals not yet evaluated
+func evalTerragruntLocals(attrs []*hclsyntax.Attribute, ctx *hcl.EvalContext, locals map[string]cty.Value) hcl.Diagnostics {
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+
+	pending := attrs
+	for len(pending) > 0 {
+		var next []*hclsyntax.Attribute
+		var diags hcl.Diagnostics
+		for _, attr := range pending {
+			val, valDiags := attr.Expr.Value(ctx)
+			if valDiags.HasErrors() {
+				next = append(next, attr)
+				diags = append(diags, valDiags...)
+				continue
+			}
+			locals[attr.Name] = val
+			ctx.Variables["local"] = cty.ObjectVal(locals)
+		}
+
+		if len(next) == len(pending) {
+			// No progress: the remaining locals have real errors or cycles
+			for _, attr := range next {
+				locals[attr.Name] = cty.DynamicVal
+			}
+			ctx.Variables["local"] = cty.ObjectVal(locals)
+			return diags
+		}
+		pending = next
+	}
+	return nil
+}
+
+// terragruntPath resolves a path used in a Terragrunt configuration
+func (l *Loader) terragruntPath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Clean(filepath.Join(baseDir, path))
+}
+
+// withinRoot reports whether a cleaned path is the upload root or inside it
+func (l *Loader) withinRoot(path string) bool {
+	return path == l.rootDir || strings.HasPrefix(path, l.rootDir+string(filepath.Separator))
+}
+
+// terragruntSource returns the directory of the module a unit deploys: its
+// terraform source if set, otherwise the unit directory if it holds
+// configuration files. Only local sources inside the upload can be loaded.
+func (l *Loader) terragruntSource(cfg *terragruntConfig, unitDir string) (string, *hcl.Diagnostic) {
+	subject := &hcl.Range{Filename: filepath.Join(unitDir, terragruntFile)}
+
+	if cfg.source == "" {
+		entries, err := os.ReadDir(unitDir)
+		if err != nil {
+			return "", nil
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && isConfigFile(entry.Name()) {
+				return unitDir, nil
+			}
+		}
+		return "", nil
+	}
+
+	// Drop query strings such as ?ref=v1.2.0; the // subdirectory
+	// separator is removed when the path is cleaned
+	source := cfg.source
+	if i := strings.Index(source, "?"); i >= 0 {
+		source = source[:i]
+	}
+	if !filepath.IsAbs(source) && !strings.HasPrefix(source, "./") && !strings.HasPrefix(source, "../") {
+		return "", &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Unsupported Terragrunt source",
+			Detail:   fmt.Sprintf("Only local module sources are supported; %q is skipped.", cfg.source),
+			Subject:  subject,
+		}
+	}
+
+	dir := l.terragruntPath(unitDir, source)
+	if !l.withinRoot(dir) {
+		return "", &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Unsupported Terragrunt source",
+			Detail:   fmt.Sprintf("Source %q is outside the configuration and is skipped.", cfg.source),
+			Subject:  subject,
+		}
+	}
+	return dir, nil
+}
+
+// loadUnit evaluates the module of a Terragrunt unit as a root module and
+// returns its plan and outputs
+func (l *Loader) loadUnit(rootDir, sourceDir, unitDir string, cfg *terragruntConfig, opts LoadOptions) (*types.TerraformPlan, cty.Value, error) {
+	plan := newPlan()
+	l.reset(rootDir, sourceDir)
+
+	parsed, ok := l.configs[sourceDir]
+	if !ok {
+		rel, _ := filepath.Rel(rootDir, sourceDir)
+		return nil, cty.NilVal, fmt.Errorf("module source %s holds no configuration files", filepath.ToSlash(rel))
+	}
+	// Units may share a module, so generated files go into a copy
+	mcfg := &moduleConfig{}
+	mcfg.merge(parsed)
+
+	// Generated files, typically the provider configuration, are written
+	// into the unit's working directory
+	var paths []string
+	for path := range cfg.generated {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if isConfigFile(path) {
+			l.addDiagnostics(plan, l.parseSource([]byte(cfg.generated[path]), filepath.Join(unitDir, path), mcfg), "")
+		}
+	}
+
+	// Terragrunt passes inputs as TF_VAR_ environment variables, so only
+	// declared variables receive them and var files take precedence
+	declared := make(map[string]bool)
+	for _, block := range mcfg.variables {
+		if len(block.Labels) > 0 {
+			declared[block.Labels[0]] = true
+		}
+	}
+	inputs := make(map[string]cty.Value)
+	for name, val := range cfg.inputs {
+		if declared[name] {
+			inputs[name] = val
+		}
+	}
+
+	values, err := l.rootVariableValues(sourceDir, opts, plan)
+	if err != nil {
+		return nil, cty.NilVal, err
+	}
+	for name, val := range values {
+		inputs[name] = val
+	}
+	l.inputs = inputs
+
+	l.evaluate(mcfg, plan)
+	plan.RequiredInputs = requiredInputs(l.required)
+	plan.References = resolveReferences(plan)
+
+	return plan, cty.ObjectVal(l.outputs), nil
+}
+
+// addStack adds the plan of a Terragrunt unit to the combined plan,
+// prefixing its addresses with the stack name
+func addStack(plan, unit *types.TerraformPlan, stack string) {
+	prefix := func(addr string) string {
+		if addr == "" {
+			return ""
+		}
+		return stack + "//" + addr
+	}
+
+	for _, r := range unit.Resources {
+		r.Address, r.Stack = prefix(r.Address), stack
+		plan.Resources = append(plan.Resources, r)
+	}
+	for _, r := range unit.DataSources {
+		r.Address, r.Stack = prefix(r.Address), stack
+		plan.DataSources = append(plan.DataSources, r)
+	}
+	for _, m := range unit.Modules {
+		plan.Modules = append(plan.Modules, prefix(m))
+	}
+	for name, val := range unit.Variables {
+		plan.Variables[prefix(name)] = val
+	}
+	for name, val := range unit.Locals {
+		plan.Locals[prefix(name)] = val
+	}
+	for name, val := range unit.Outputs {
+		plan.Outputs[prefix(name)] = val
+	}
+	for _, d := range unit.Diagnostics {
+		if d.Address != "" {
+			d.Address = prefix(d.Address)
+		} else {
+			d.Address = stack
+		}
+		plan.Diagnostics = append(plan.Diagnostics, d)
+	}
+	for _, d := range unit.NotCreated {
+		d.Address = prefix(d.Address)
+		plan.NotCreated = append(plan.NotCreated, d)
+	}
+	for _, input := range unit.RequiredInputs {
+		input.Stack = stack
+		usedBy := make([]types.InputUsage, 0, len(input.UsedBy))
+		for _, usage := range input.UsedBy {
+			usage.Address = prefix(usage.Address)
+			usedBy = append(usedBy, usage)
+		}
+		input.UsedBy = usedBy
+		plan.RequiredInputs = append(plan.RequiredInputs, input)
+	}
+	for _, ref := range unit.References {
+		ref.From, ref.To = prefix(ref.From), prefix(ref.To)
+		plan.References = append(plan.References, ref)
+	}
+}
+
+// terragruntFunctions returns the Terraform functions plus the Terragrunt
+// built-ins commonly used to locate and share configuration
+func (l *Loader) terragruntFunctions(unitDir, fileDir string, depth int) map[string]function.Function {
+	funcs := functions(l.rootDir, fileDir)
+
+	funcs["find_in_parent_folders"] = function.New(&function.Spec{
+		VarParam: &function.Parameter{Name: "args", Type: cty.String},
+		Type:     function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			name := terragruntFile
+			if len(args) > 0 {
+				name = args[0].AsString()
+			}
+			// Search upwards from the unit's parent, stopping at the upload root
+			for dir := filepath.Dir(unitDir); l.withinRoot(dir); dir = filepath.Dir(dir) {
+				path := filepath.Join(dir, name)
+				if !l.withinRoot(path) {
+					break
+				}
+				if _, err := os.Stat(path); err == nil {
+					return cty.StringVal(path), nil
+				}
+				if dir == l.rootDir {
+					break
+				}
+			}
+			if len(args) > 1 {
+				return args[1], nil
+			}
+			return cty.NilVal, fmt.Errorf("could not find %s in any parent folder", name)
+		},
+	})
+	funcs["get_terragrunt_dir"] = stringFunc(unitDir)
+	funcs["get_parent_terragrunt_dir"] = stringFunc(fileDir)
+	funcs["get_original_terragrunt_dir"] = stringFunc(unitDir)
+	funcs["get_repo_root"] = stringFunc(l.rootDir)
+	funcs["path_relative_to_include"] = stringFunc(relativePath(fileDir, unitDir))
+	funcs["path_relative_from_include"] = stringFunc(relativePath(unitDir, fileDir))
+	funcs["get_terraform_commands_that_need_vars"] = function.New(&function.Spec{
+		Type: function.StaticReturnType(cty.List(cty.String)),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			var commands []cty.Value
+			for _, c := range []string{"apply", "console", "destroy", "import", "plan", "push", "refresh"} {
+				commands = append(commands, cty.StringVal(c))
+			}
+			return cty.ListVal(commands), nil
+		},
+	})
+
+	// The server's environment and AWS identity are not the deployer's,
+	// so these yield the default if given and are unknown otherwise
+	funcs["get_env"] = function.New(&function.Spec{
+		VarParam: &function.Parameter{Name: "args", Type: cty.String},
+		Type:     function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			if len(args) > 1 {
+				return args[1], nil
+			}
+			return cty.UnknownVal(cty.String), nil
+		},
+	})
+	for _, name := range []string{"get_aws_account_id", "get_aws_caller_identity_arn", "get_aws_caller_identity_user_id"} {
+		funcs[name] = function.New(&function.Spec{
+			Type: function.StaticReturnType(cty.String),
+			Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+				return cty.UnknownVal(cty.String), nil
+			},
+		})
+	}
+
+	funcs["read_terragrunt_config"] = function.New(&function.Spec{
+		Params:   []function.Parameter{{Name: "path", Type: cty.String}},
+		VarParam: &function.Parameter{Name: "default", Type: cty.DynamicPseudoType},
+		Type:     function.StaticReturnType(cty.DynamicPseudoType),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path := l.terragruntPath(fileDir, args[0].AsString())
+			if !l.withinRoot(path) {
+				return cty.NilVal, fmt.Errorf("%s is outside the configuration", args[0].AsString())
+			}
+			if depth >= maxIncludeDepth {
+				return cty.NilVal, fmt.Errorf("configurations are nested more than %d levels", maxIncludeDepth)
+			}
+
+			cfg, diags := l.parseTerragrunt(path, unitDir, nil, depth+1)
+			if diags.HasErrors() {
+				if len(args) > 1 {
+					return args[1], nil
+				}
+				return cty.NilVal, fmt.Errorf("failed to read %s: %s", args[0].AsString(), diags.Error())
+			}
+			return cty.ObjectVal(map[string]cty.Value{
+				"locals": cty.ObjectVal(cfg.locals),
+				"inputs": cty.ObjectVal(cfg.inputs),
+			}), nil
+		},
+	})
+
+	return funcs
+}
+
+// stringFunc returns a function with no arguments that returns s
+func stringFunc(s string) function.Function {
+	return function.New(&function.Spec{
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return cty.StringVal(s), nil
+		},
+	})
+}
+
+// relativePath returns target relative to base with forward slashes
+func relativePath(base, target string) string {
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(rel)
+}
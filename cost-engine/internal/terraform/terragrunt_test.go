@@ -0,0 +1,84 @@
+package terraform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTerragruntUnits(t *testing.T) {
+	module := `
+variable "instance_type" {}
+variable "subnet_id" {
+  default = "none"
+}
+
+resource "aws_instance" "web" {
+  instance_type = var.instance_type
+  subnet_id     = var.subnet_id
+}
+
+output "subnet_id" {
+  value = "subnet-123"
+}
+`
+	plan := loadFiles(t, map[string]string{
+		"modules/app/main.tf": module,
+		"live/network/terragrunt.hcl": `
+terraform {
+  source = "../../modules/app"
+}
+
+inputs = {
+  instance_type = "t3.micro"
+}
+`,
+		"live/app/terragrunt.hcl": `
+terraform {
+  source = "../../modules/app"
+}
+
+dependency "network" {
+  config_path = "../network"
+  mock_outputs = {
+    subnet_id = "subnet-mock"
+  }
+}
+
+inputs = {
+  instance_type = "m5.large"
+  subnet_id     = dependency.network.outputs.subnet_id
+}
+`,
+	})
+
+	want := map[string][2]string{
+		"live/app//aws_instance.web":     {"m5.large", "subnet-123"},
+		"live/network//aws_instance.web": {"t3.micro", "none"},
+	}
+	got := make(map[string][2]string)
+	for _, res := range plan.Resources {
+		got[res.Address] = [2]string{res.Config["instance_type"].(string), res.Config["subnet_id"].(string)}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resources = %v, want %v", got, want)
+	}
+}
+
+func TestTerragruntFileInTerraformRoot(t *testing.T) {
+	plan := loadFiles(t, map[string]string{
+		"main.tf": `
+resource "aws_instance" "web" {
+  instance_type = "t3.micro"
+}
+`,
+		"examples/unit/terragrunt.hcl": `
+terraform {
+  source = "../.."
+}
+`,
+	})
+
+	if got := resourceAddresses(plan); !reflect.DeepEqual(got, []string{"aws_instance.web"}) {
+		t.Errorf("resources = %v, want the Terraform root module only", got)
+	}
+}
@@ -0,0 +1,161 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/santoshpalla27/aws-cost-estimation/cost-engine/internal/types"
+)
+
+// LoadOptions supplies root module variable values in addition to the
+// var files Terraform loads automatically. For Terragrunt uploads they
+// apply to every unit, after the unit's inputs.
+type LoadOptions struct {
+	// VarFiles are .tfvars or .tfvars.json files relative to the
+	// configuration root, applied in order after the automatic files
+	VarFiles []string
+	// Variables are explicit values that take precedence over all var files
+	Variables map[string]interface{}
+}
+
+// rootVariableValues collects root module variable values following
+// Terraform's precedence: terraform.tfvars, terraform.tfvars.json,
+// *.auto.tfvars(.json) in lexical order, named var files, then explicit values
+func (l *Loader) rootVariableValues(dir string, opts LoadOptions, plan *types.TerraformPlan) (map[string]cty.Value, error) {
+	values := make(map[string]cty.Value)
+
+	var files []string
+	for _, name := range []string{"terraform.tfvars", "terraform.tfvars.json"} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var autoFiles []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && (strings.HasSuffix(name, ".auto.tfvars") || strings.HasSuffix(name, ".auto.tfvars.json")) {
+			autoFiles = append(autoFiles, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(autoFiles)
+	files = append(files, autoFiles...)
+
+	// Named var files are relative to the upload root, so the same files
+	// apply to every Terragrunt unit
+	for _, name := range opts.VarFiles {
+		path := filepath.Join(l.rootDir, filepath.Clean(name))
+		if !l.withinRoot(path) {
+			return nil, fmt.Errorf("var file %q is outside the configuration", name)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("var file %q not found", name)
+		}
+		files = append(files, path)
+	}
+
+	// A malformed file is reported and skipped, so the estimate goes on
+	// with the values the other files set
+	for _, path := range files {
+		l.addDiagnostics(plan, l.parseVarFile(path, values), "")
+	}
+
+	for name, raw := range opts.Variables {
+		val, err := goToCty(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for variable %q: %w", name, err)
+		}
+		values[name] = val
+	}
+
+	return values, nil
+}
+
+// parseVarFile reads a .tfvars (HCL) or .tfvars.json file into values
+func (l *Loader) parseVarFile(path string, values map[string]cty.Value) hcl.Diagnostics {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to read variables file",
+			Detail:   fmt.Sprintf("Variables file %s could not be read and is skipped.", filepath.Base(path)),
+		}}
+	}
+
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if strings.HasSuffix(path, ".json") {
+		file, diags = l.parser.ParseJSON(src, path)
+	} else {
+		file, diags = l.parser.ParseHCL(src, path)
+	}
+	if diags.HasErrors() {
+		return diags
+	}
+
+	// Blocks are reported, but the file's valid attributes still apply
+	attrs, attrDiags := file.Body.JustAttributes()
+	diags = append(diags, attrDiags...)
+
+	for name, attr := range attrs {
+		val, valDiags := attr.Expr.Value(nil)
+		diags = append(diags, valDiags...)
+		if valDiags.HasErrors() {
+			continue
+		}
+		values[name] = val
+	}
+
+	return diags
+}
+
+// variableType returns the declared type constraint of a variable block,
+// or cty.DynamicPseudoType when none is declared
+func variableType(block *configBlock) (cty.Type, *typeexpr.Defaults, hcl.Diagnostics) {
+	attr, ok := block.Body.Attributes["type"]
+	if !ok {
+		return cty.DynamicPseudoType, nil, nil
+	}
+	return typeexpr.TypeConstraintWithDefaults(attr.Expr)
+}
+
+// convertVariable converts a value to the variable's declared type,
+// applying optional attribute defaults
+func convertVariable(val cty.Value, ty cty.Type, defaults *typeexpr.Defaults) (cty.Value, error) {
+	if defaults != nil {
+		val = defaults.Apply(val)
+	}
+	return convert.Convert(val, ty)
+}
+
+// goToCty converts a decoded JSON value to a cty value
+func goToCty(raw interface{}) (cty.Value, error) {
+	if raw == nil {
+		return cty.NullVal(cty.DynamicPseudoType), nil
+	}
+
+	src, err := json.Marshal(raw)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	ty, err := ctyjson.ImpliedType(src)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(src, ty)
+}
@@ -0,0 +1,60 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVariablePrecedence(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.tf": `
+variable "a" { default = "default" }
+variable "b" { default = "default" }
+variable "c" { default = "default" }
+variable "d" { default = "default" }
+variable "e" { default = "default" }
+
+resource "aws_instance" "web" {
+  tags = { a = var.a, b = var.b, c = var.c, d = var.d, e = var.e }
+}
+`,
+		"terraform.tfvars":   `a = "tfvars"` + "\n" + `b = "tfvars"` + "\n" + `c = "tfvars"` + "\n" + `d = "tfvars"`,
+		"a.auto.tfvars":      `b = "a.auto"` + "\n" + `c = "a.auto"`,
+		"b.auto.tfvars.json": `{"c": "b.auto", "d": "b.auto"}`,
+		"broken.auto.tfvars": `e = = "broken"`,
+		"prod.tfvars":        `d = "named"`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plan, err := NewLoader().LoadDirectory(dir, LoadOptions{
+		VarFiles:  []string{"prod.tfvars"},
+		Variables: map[string]interface{}{"a": "explicit"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"a": "explicit", "b": "a.auto", "c": "b.auto", "d": "named", "e": "default"}
+	tags, _ := plan.Resources[0].Config["tags"].(map[string]interface{})
+	for name, value := range want {
+		if tags[name] != value {
+			t.Errorf("var.%s = %v, want %s", name, tags[name], value)
+		}
+	}
+
+	// The malformed file is reported but does not fail the load
+	if len(plan.Diagnostics) == 0 {
+		t.Error("no diagnostic for the malformed variables file")
+	}
+
+	// Named var files may not leave the configuration
+	if _, err := NewLoader().LoadDirectory(dir, LoadOptions{VarFiles: []string{"../secrets.tfvars"}}); err == nil {
+		t.Error("var file outside the configuration was accepted")
+	}
+}
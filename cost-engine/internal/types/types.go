@@ -17,36 +17,70 @@ type UsageVector struct {
 	Service         string            `json:"service"`          // e.g., AmazonEC2
 	UsageType       string            `json:"usage_type"`       // e.g., BoxUsage:t3.micro
 	Operation       string            `json:"operation,omitempty"`
-	Region          string            `json:"region"`           // e.g., us-east-1
-	Unit            string            `json:"unit"`             // e.g., Hrs
-	Quantity        float64           `json:"quantity"`         // e.g., 730 (hours/month)
+	Region          string            `json:"region"`   // e.g., us-east-1
+	Unit            string            `json:"unit"`     // e.g., Hrs
+	Quantity        float64           `json:"quantity"` // e.g., 730 (hours/month)
 	Attributes      map[string]string `json:"attributes,omitempty"`
 	Confidence      Confidence        `json:"confidence"`
 	Assumptions     []string          `json:"assumptions,omitempty"`
+	PurchaseOption  *PurchaseOption   `json:"purchase_option,omitempty"` // Commitment to price under; on demand if nil
+	Market          Market            `json:"market,omitempty"`          // Capacity market; on demand if empty
+}
+
+// Market is the capacity market instance hours are bought in
+type Market string
+
+const (
+	MarketSpot Market = "spot"
+)
+
+// PurchaseTerm is how compute capacity is paid for
+type PurchaseTerm string
+
+const (
+	PurchaseOnDemand            PurchaseTerm = "on_demand"
+	PurchaseReserved            PurchaseTerm = "reserved"
+	PurchaseComputeSavingsPlan  PurchaseTerm = "compute_savings_plan"
+	PurchaseInstanceSavingsPlan PurchaseTerm = "ec2_instance_savings_plan"
+)
+
+// PurchaseOption is a Reserved Instance or Savings Plans commitment to
+// estimate instance usage under
+type PurchaseOption struct {
+	Term          PurchaseTerm `json:"term"`
+	Length        string       `json:"length,omitempty"`         // 1yr or 3yr; default 1yr
+	Payment       string       `json:"payment,omitempty"`        // no_upfront, partial_upfront or all_upfront; default no_upfront
+	OfferingClass string       `json:"offering_class,omitempty"` // standard or convertible, Reserved only; default standard
 }
 
 // PricedItem represents a usage vector with pricing applied
 type PricedItem struct {
 	UsageVector
-	PricePerUnit    float64    `json:"price_per_unit"`
-	MonthlyCost     float64    `json:"monthly_cost"`
-	Currency        string     `json:"currency"`
-	MatchConfidence Confidence `json:"match_confidence"`
-	MatchScore      float64    `json:"match_score"`      // 0-1 score
-	PricingSource   string     `json:"pricing_source"`   // SKU or rate code
-	Formula         string     `json:"formula"`           // e.g., "730 hrs × $0.0116/hr"
+	PricePerUnit        float64    `json:"price_per_unit"`
+	MonthlyCost         float64    `json:"monthly_cost"`
+	Currency            string     `json:"currency"`
+	MatchConfidence     Confidence `json:"match_confidence"`
+	MatchScore          float64    `json:"match_score"`                      // 0-1 score
+	PricingSource       string     `json:"pricing_source"`                   // SKU or rate code
+	Formula             string     `json:"formula"`                          // e.g., "730 hrs × $0.0116/hr"
+	UpfrontCost         float64    `json:"upfront_cost,omitempty"`           // One-time commitment fee, included in MonthlyCost spread over the term
+	OnDemandMonthlyCost float64    `json:"on_demand_monthly_cost,omitempty"` // On-demand cost for comparison, when priced under a commitment or on Spot
 }
 
 // ResourceCost aggregates all costs for a single Terraform resource
 type ResourceCost struct {
-	Address      string        `json:"address"`       // e.g., aws_instance.web
-	Type         string        `json:"type"`          // e.g., aws_instance
-	Name         string        `json:"name"`          // e.g., web
-	Service      string        `json:"service"`       // e.g., AmazonEC2
-	MonthlyCost  float64       `json:"monthly_cost"`
-	Confidence   Confidence    `json:"confidence"`
-	LineItems    []PricedItem  `json:"line_items"`
-	Assumptions  []string      `json:"assumptions,omitempty"`
+	Address             string       `json:"address"` // e.g., aws_instance.web
+	Type                string       `json:"type"`    // e.g., aws_instance
+	Name                string       `json:"name"`    // e.g., web
+	Service             string       `json:"service"` // e.g., AmazonEC2
+	MonthlyCost         float64      `json:"monthly_cost"`
+	Confidence          Confidence   `json:"confidence"`
+	LineItems           []PricedItem `json:"line_items"`
+	Assumptions         []string     `json:"assumptions,omitempty"`
+	Source              *SourceRange `json:"source,omitempty"`                 // Where the resource is declared, e.g. modules/db/main.tf:42
+	Stack               string       `json:"stack,omitempty"`                  // Terragrunt unit the resource belongs to
+	UpfrontCost         float64      `json:"upfront_cost,omitempty"`           // One-time commitment fees
+	OnDemandMonthlyCost float64      `json:"on_demand_monthly_cost,omitempty"` // Cost on demand, when commitments or Spot apply
 }
 
 // ServiceCost aggregates costs by AWS service
@@ -57,16 +91,90 @@ type ServiceCost struct {
 	Confidence   Confidence    `json:"confidence"`
 }
 
+// StackCost aggregates costs by Terragrunt unit
+type StackCost struct {
+	Stack         string     `json:"stack"`
+	MonthlyCost   float64    `json:"monthly_cost"`
+	ResourceCount int        `json:"resource_count"`
+	Confidence    Confidence `json:"confidence"`
+}
+
 // CostEstimate is the complete cost estimation result
 type CostEstimate struct {
-	TotalMonthlyCost float64               `json:"total_monthly_cost"`
-	Currency         string                `json:"currency"`
-	ByService        map[string]ServiceCost `json:"by_service"`
-	ByResource       []ResourceCost        `json:"by_resource"`
-	OverallConfidence Confidence           `json:"overall_confidence"`
-	Assumptions      []string              `json:"assumptions"`
-	Warnings         []string              `json:"warnings,omitempty"`
-	Metadata         EstimateMetadata      `json:"metadata"`
+	TotalMonthlyCost    float64                `json:"total_monthly_cost"`
+	TotalUpfrontCost    float64                `json:"total_upfront_cost,omitempty"`     // One-time commitment fees
+	OnDemandMonthlyCost float64                `json:"on_demand_monthly_cost,omitempty"` // Total on demand, when commitments or Spot apply
+	Currency            string                 `json:"currency"`
+	ByService           map[string]ServiceCost `json:"by_service"`
+	ByResource          []ResourceCost         `json:"by_resource"`
+	ByStack             map[string]StackCost   `json:"by_stack,omitempty"` // Per Terragrunt unit, when the input has several
+	OverallConfidence   Confidence             `json:"overall_confidence"`
+	Assumptions         []string               `json:"assumptions"`
+	Warnings            []string               `json:"warnings,omitempty"`
+	Metadata            EstimateMetadata       `json:"metadata"`
+	Current             *CostEstimate          `json:"current,omitempty"`     // Deployed footprint from state, when supplied
+	NotCreated          []DisabledResource     `json:"not_created,omitempty"` // Resources disabled by count = 0 or an empty for_each
+	Diagnostics         []Diagnostic           `json:"diagnostics,omitempty"` // Problems found while parsing the input
+}
+
+// DiffAction describes how a resource changed between two estimates
+type DiffAction string
+
+const (
+	DiffAdded   DiffAction = "added"
+	DiffRemoved DiffAction = "removed"
+	DiffChanged DiffAction = "changed"
+)
+
+// CostDiff compares a baseline estimate with a proposed one
+type CostDiff struct {
+	Currency            string                      `json:"currency"`
+	BaselineMonthlyCost float64                     `json:"baseline_monthly_cost"`
+	ProposedMonthlyCost float64                     `json:"proposed_monthly_cost"`
+	MonthlyCostDelta    float64                     `json:"monthly_cost_delta"` // e.g. +312.00 for "adds $312/mo"
+	Resources           map[string]ResourceCostDiff `json:"resources"`          // Keyed by address; unchanged resources omitted
+	AddedCount          int                         `json:"added_count"`
+	RemovedCount        int                         `json:"removed_count"`
+	ChangedCount        int                         `json:"changed_count"`
+	ByService           map[string]ServiceCostDiff  `json:"by_service"`
+	Baseline            EstimateMetadata            `json:"baseline"`
+	Proposed            EstimateMetadata            `json:"proposed"`
+}
+
+// ResourceCostDiff is the cost change of a single resource
+type ResourceCostDiff struct {
+	Address             string         `json:"address"`
+	Type                string         `json:"type"`
+	Name                string         `json:"name"`
+	Service             string         `json:"service"`
+	Change              DiffAction     `json:"change"`
+	BaselineMonthlyCost float64        `json:"baseline_monthly_cost"`
+	ProposedMonthlyCost float64        `json:"proposed_monthly_cost"`
+	MonthlyCostDelta    float64        `json:"monthly_cost_delta"`
+	LineItems           []LineItemDiff `json:"line_items"`
+	Source              *SourceRange   `json:"source,omitempty"` // Proposed declaration, or the baseline one if removed
+}
+
+// LineItemDiff is the change of a single priced line item
+type LineItemDiff struct {
+	Service             string  `json:"service"`
+	UsageType           string  `json:"usage_type"`
+	Unit                string  `json:"unit"`
+	BaselineQuantity    float64 `json:"baseline_quantity"`
+	ProposedQuantity    float64 `json:"proposed_quantity"`
+	BaselineMonthlyCost float64 `json:"baseline_monthly_cost"`
+	ProposedMonthlyCost float64 `json:"proposed_monthly_cost"`
+	MonthlyCostDelta    float64 `json:"monthly_cost_delta"`
+	BaselineFormula     string  `json:"baseline_formula,omitempty"`
+	ProposedFormula     string  `json:"proposed_formula,omitempty"`
+}
+
+// ServiceCostDiff is the cost change of an AWS service
+type ServiceCostDiff struct {
+	Service             string  `json:"service"`
+	BaselineMonthlyCost float64 `json:"baseline_monthly_cost"`
+	ProposedMonthlyCost float64 `json:"proposed_monthly_cost"`
+	MonthlyCostDelta    float64 `json:"monthly_cost_delta"`
 }
 
 // EstimateMetadata contains reproducibility information
@@ -79,23 +187,104 @@ type EstimateMetadata struct {
 
 // TerraformResource represents a parsed Terraform resource
 type TerraformResource struct {
-	Type       string                 `json:"type"`       // e.g., aws_instance
-	Name       string                 `json:"name"`       // e.g., web
-	Address    string                 `json:"address"`    // e.g., aws_instance.web
-	Provider   string                 `json:"provider"`   // e.g., aws
-	Config     map[string]interface{} `json:"config"`     // Evaluated configuration
-	Count      int                    `json:"count"`      // Number of instances
-	ForEach    []string               `json:"for_each,omitempty"` // Keys if for_each used
-	DependsOn  []string               `json:"depends_on,omitempty"`
-	Module     string                 `json:"module,omitempty"` // Module path if nested
+	Type      string                 `json:"type"`               // e.g., aws_instance
+	Name      string                 `json:"name"`               // e.g., web
+	Address   string                 `json:"address"`            // e.g., aws_instance.web
+	Provider  string                 `json:"provider"`           // e.g., aws
+	Region    string                 `json:"region,omitempty"`   // Region of the resource's provider configuration, if known
+	Config    map[string]interface{} `json:"config"`             // Evaluated configuration
+	Count     int                    `json:"count"`              // Number of instances
+	ForEach   []string               `json:"for_each,omitempty"` // Keys if for_each used
+	DependsOn []string               `json:"depends_on,omitempty"`
+	Module    string                 `json:"module,omitempty"`   // Module path if nested
+	Stack     string                 `json:"stack,omitempty"`    // Terragrunt unit directory, e.g. live/prod/vpc
+	Source    *SourceRange           `json:"source,omitempty"`   // Where the resource is declared
+	Platform  *Platform              `json:"platform,omitempty"` // Operating system and licensing of its machine image, if resolved
+}
+
+// Platform is the operating system and licensing of a machine image, in the
+// attribute values the pricing catalog uses
+type Platform struct {
+	OperatingSystem string `json:"operating_system"`           // e.g. Linux, Windows, RHEL, SUSE
+	PreInstalledSw  string `json:"pre_installed_sw,omitempty"` // e.g. NA, SQL Std
+	LicenseModel    string `json:"license_model,omitempty"`    // e.g. No License required, Bring your own license
+	PlatformDetails string `json:"platform_details"`           // EC2 platform details, e.g. Windows with SQL Server Standard
+	Source          string `json:"source"`                     // Where it was resolved from, e.g. data.aws_ami.windows
+}
+
+// DisabledResource is a resource or module call that creates no instances
+// because its count is 0 or its for_each is empty
+type DisabledResource struct {
+	Address   string `json:"address"` // e.g., aws_nat_gateway.this
+	Type      string `json:"type"`    // e.g., aws_nat_gateway, or module
+	Name      string `json:"name"`
+	Module    string `json:"module,omitempty"`
+	Condition string `json:"condition"` // e.g., count = var.create_nat ? 1 : 0
+	Reason    string `json:"reason"`    // e.g., count is 0
 }
 
 // TerraformPlan represents a fully parsed Terraform configuration
 type TerraformPlan struct {
-	Resources  []TerraformResource `json:"resources"`
-	Variables  map[string]interface{} `json:"variables"`
-	Locals     map[string]interface{} `json:"locals"`
-	DataSources []TerraformResource  `json:"data_sources"`
-	Outputs    map[string]interface{} `json:"outputs"`
-	Modules    []string              `json:"modules"`
+	Resources      []TerraformResource    `json:"resources"`
+	Variables      map[string]interface{} `json:"variables"`
+	Locals         map[string]interface{} `json:"locals"`
+	DataSources    []TerraformResource    `json:"data_sources"`
+	Outputs        map[string]interface{} `json:"outputs"`
+	Modules        []string               `json:"modules"`
+	Diagnostics    []Diagnostic           `json:"diagnostics,omitempty"`
+	NotCreated     []DisabledResource     `json:"not_created,omitempty"`
+	RequiredInputs []RequiredInput        `json:"required_inputs,omitempty"`
+	References     []ResourceReference    `json:"references,omitempty"` // Implicit references between resources
+}
+
+// ResourceReference is an attribute of one resource instance that refers to
+// another resource or data source instance, e.g. aws_eip.ip's instance
+// argument set to aws_instance.web.id
+type ResourceReference struct {
+	From      string `json:"from"`      // Referencing instance, e.g. aws_eip.ip
+	To        string `json:"to"`        // Referenced instance, e.g. aws_instance.web[0]
+	Attribute string `json:"attribute"` // e.g. instance or network_interface.subnet_id
+}
+
+// RequiredInput is a variable that has neither a value nor a default
+type RequiredInput struct {
+	Name        string       `json:"name"`
+	Module      string       `json:"module,omitempty"` // Module path if declared in a child module
+	Stack       string       `json:"stack,omitempty"`  // Terragrunt unit, if any
+	Type        string       `json:"type"`             // Declared type constraint, e.g. map(string)
+	Description string       `json:"description,omitempty"`
+	UsedBy      []InputUsage `json:"used_by"` // Resource attributes whose values depend on it
+}
+
+// InputUsage is a resource attribute that depends on a required input
+type InputUsage struct {
+	Address      string `json:"address"`
+	ResourceType string `json:"resource_type"`
+	Attribute    string `json:"attribute"` // e.g. instance_type or root_block_device.volume_size
+}
+
+// DiagnosticSeverity classifies a diagnostic as an error or a warning
+type DiagnosticSeverity string
+
+const (
+	SeverityError   DiagnosticSeverity = "error"
+	SeverityWarning DiagnosticSeverity = "warning"
+)
+
+// Diagnostic describes a problem found while loading a configuration
+type Diagnostic struct {
+	Severity DiagnosticSeverity `json:"severity"`
+	Summary  string             `json:"summary"`
+	Detail   string             `json:"detail,omitempty"`
+	Range    *SourceRange       `json:"range,omitempty"`
+	Address  string             `json:"address,omitempty"` // Resource, module or value the diagnostic relates to
+}
+
+// SourceRange identifies a span of source code
+type SourceRange struct {
+	Filename    string `json:"filename"`
+	StartLine   int    `json:"start_line"`
+	StartColumn int    `json:"start_column"`
+	EndLine     int    `json:"end_line"`
+	EndColumn   int    `json:"end_column"`
 }
@@ -8,6 +8,7 @@ import ora from 'ora';
 import chalk from 'chalk';
 import { initializeSchema, closeDatabase, getIngestionStats } from '../db/index.js';
 import { runIngestion, listAvailableServices } from '../ingestion/orchestrator.js';
+import { importSpotPriceHistory } from '../ingestion/spot-importer.js';
 import { logger } from '../utils/logger.js';
 
 interface IngestOptions {
@@ -161,6 +162,33 @@ program
         }
     });
 
+program
+    .command('import-spot')
+    .description('Import EC2 spot price history (describe-spot-price-history JSON)')
+    .argument('<files...>', 'JSON files to import')
+    .action(async (files: string[]) => {
+        const spinner = ora('Importing spot price history...').start();
+
+        try {
+            await initializeSchema();
+            const results = await importSpotPriceHistory(files);
+            spinner.succeed('Spot price history imported');
+
+            results.forEach((r) => {
+                console.log(
+                    chalk.green(`  • ${r.file}: ${r.imported.toLocaleString()} new prices of ${r.entries.toLocaleString()}`) +
+                        (r.skipped > 0 ? chalk.yellow(` (${r.skipped} unsupported skipped)`) : '')
+                );
+            });
+        } catch (error) {
+            spinner.fail('Failed to import spot price history');
+            console.error(chalk.red((error as Error).message));
+            process.exit(1);
+        } finally {
+            await closeDatabase();
+        }
+    });
+
 program
     .command('init-db')
     .description('Initialize database schema')
@@ -13,6 +13,7 @@ import type {
     CatalogVersion,
     NormalizedPricingDimension,
     AttributeMapping,
+    SpotPrice,
 } from '../types/pricing.js';
 
 const { Pool } = pg;
@@ -97,6 +98,20 @@ export async function initializeSchema(): Promise<void> {
         expires_at TIMESTAMPTZ
       );
 
+      -- Spot price history (imported from describe-spot-price-history)
+      CREATE TABLE IF NOT EXISTS spot_price_history (
+        id BIGSERIAL PRIMARY KEY,
+        region_code VARCHAR(32) NOT NULL,
+        availability_zone VARCHAR(32) NOT NULL,
+        instance_type VARCHAR(64) NOT NULL,
+        product_description VARCHAR(64) NOT NULL,
+        operating_system VARCHAR(32) NOT NULL,
+        spot_price DECIMAL(24, 12) NOT NULL,
+        timestamp TIMESTAMPTZ NOT NULL,
+        imported_at TIMESTAMPTZ DEFAULT NOW(),
+        UNIQUE(availability_zone, instance_type, product_description, timestamp)
+      );
+
       -- Indexes for fast lookups
       CREATE INDEX IF NOT EXISTS idx_pricing_lookup 
         ON pricing_dimensions(service, region_code, usage_type);
@@ -109,6 +124,9 @@ export async function initializeSchema(): Promise<void> {
       CREATE INDEX IF NOT EXISTS idx_pricing_attributes 
         ON pricing_dimensions USING GIN(attributes);
       
+      CREATE INDEX IF NOT EXISTS idx_spot_lookup
+        ON spot_price_history(region_code, instance_type, operating_system);
+
       CREATE INDEX IF NOT EXISTS idx_mappings_lookup 
         ON attribute_mappings(mapping_type, source_value);
       CREATE INDEX IF NOT EXISTS idx_mappings_catalog 
@@ -284,6 +302,47 @@ export async function bulkInsertAttributeMappings(
     }
 }
 
+/**
+ * Bulk insert spot prices, skipping samples that were already imported
+ */
+export async function bulkInsertSpotPrices(prices: SpotPrice[]): Promise<number> {
+    if (prices.length === 0) return 0;
+
+    const client = await pool.connect();
+    try {
+        const values: unknown[] = [];
+        const placeholders: string[] = [];
+
+        prices.forEach((p, i) => {
+            const offset = i * 7;
+            placeholders.push(
+                `($${offset + 1}, $${offset + 2}, $${offset + 3}, $${offset + 4}, $${offset + 5}, $${offset + 6}, $${offset + 7})`
+            );
+            values.push(
+                p.regionCode,
+                p.availabilityZone,
+                p.instanceType,
+                p.productDescription,
+                p.operatingSystem,
+                p.spotPrice,
+                p.timestamp
+            );
+        });
+
+        const result = await client.query(
+            `INSERT INTO spot_price_history (region_code, availability_zone, instance_type,
+         product_description, operating_system, spot_price, timestamp)
+       VALUES ${placeholders.join(', ')}
+       ON CONFLICT (availability_zone, instance_type, product_description, timestamp) DO NOTHING`,
+            values
+        );
+
+        return result.rowCount ?? 0;
+    } finally {
+        client.release();
+    }
+}
+
 /**
  * Check if a catalog version already exists
  */
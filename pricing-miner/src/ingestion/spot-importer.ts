@@ -0,0 +1,98 @@
+/**
+ * Spot Price History Importer
+ * Loads `aws ec2 describe-spot-price-history` output into the warehouse
+ */
+
+import { readFile } from 'node:fs/promises';
+import { bulkInsertSpotPrices } from '../db/index.js';
+import { logger } from '../utils/logger.js';
+import type { SpotPrice, SpotPriceHistoryEntry, SpotPriceHistoryFile } from '../types/pricing.js';
+
+// Rows per INSERT, kept well below the 65535 bind parameter limit
+const INSERT_BATCH_SIZE = 5000;
+
+// Product descriptions mapped to the catalog's operatingSystem values
+const OPERATING_SYSTEMS: Record<string, string> = {
+    'Linux/UNIX': 'Linux',
+    'SUSE Linux': 'SUSE',
+    'Red Hat Enterprise Linux': 'RHEL',
+    'Windows': 'Windows',
+};
+
+export interface SpotImportResult {
+    file: string;
+    entries: number;
+    imported: number;
+    skipped: number;
+}
+
+/**
+ * Import spot price history files. Each file is the JSON output of
+ * `aws ec2 describe-spot-price-history`, or just its SpotPriceHistory array.
+ */
+export async function importSpotPriceHistory(files: string[]): Promise<SpotImportResult[]> {
+    const results: SpotImportResult[] = [];
+
+    for (const file of files) {
+        const content = JSON.parse(await readFile(file, 'utf8')) as
+            | SpotPriceHistoryFile
+            | SpotPriceHistoryEntry[];
+        const entries = Array.isArray(content) ? content : content.SpotPriceHistory ?? [];
+
+        const prices: SpotPrice[] = [];
+        for (const entry of entries) {
+            const price = normalizeSpotPrice(entry);
+            if (price) prices.push(price);
+        }
+
+        let imported = 0;
+        for (let i = 0; i < prices.length; i += INSERT_BATCH_SIZE) {
+            imported += await bulkInsertSpotPrices(prices.slice(i, i + INSERT_BATCH_SIZE));
+        }
+
+        const result = {
+            file,
+            entries: entries.length,
+            imported,
+            skipped: entries.length - prices.length,
+        };
+        logger.info(result, 'Imported spot price history');
+        results.push(result);
+    }
+
+    return results;
+}
+
+/**
+ * Normalize a spot price history entry, or return null when its region or
+ * product cannot be priced
+ */
+function normalizeSpotPrice(entry: SpotPriceHistoryEntry): SpotPrice | null {
+    const regionCode = regionFromZone(entry.AvailabilityZone ?? '');
+    const productDescription = (entry.ProductDescription ?? '').replace(/ \(Amazon VPC\)$/, '');
+    const operatingSystem = OPERATING_SYSTEMS[productDescription];
+    const spotPrice = parseFloat(entry.SpotPrice);
+
+    if (!regionCode || !operatingSystem || !entry.InstanceType || isNaN(spotPrice) || !entry.Timestamp) {
+        return null;
+    }
+
+    return {
+        regionCode,
+        availabilityZone: entry.AvailabilityZone,
+        instanceType: entry.InstanceType,
+        productDescription,
+        operatingSystem,
+        spotPrice,
+        timestamp: entry.Timestamp,
+    };
+}
+
+/**
+ * Derive the region of an availability zone or local zone, e.g. us-east-1a
+ * and us-west-2-lax-1a both map to their parent region
+ */
+function regionFromZone(zone: string): string | null {
+    const match = /^([a-z]{2}(?:-gov)?-[a-z]+-\d+)/.exec(zone);
+    return match ? match[1]! : null;
+}
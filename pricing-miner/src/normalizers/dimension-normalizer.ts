@@ -116,6 +116,12 @@ export function normalizeTermToDimensions(
     const usageType = extractUsageType(attributes);
     const operation = extractOperation(attributes);
 
+    // Reserved terms carry their lease length, purchase option and offering
+    // class as term attributes; keep them so commitments can be matched
+    const rowAttributes = termType === 'Reserved' && term.termAttributes
+        ? { ...attributes, ...term.termAttributes }
+        : attributes;
+
     // Each term can have multiple price dimensions (tiered pricing)
     for (const [rateCode, priceDim] of Object.entries(term.priceDimensions ?? {})) {
         const { price, currency } = parsePrice(priceDim.pricePerUnit);
@@ -136,7 +142,7 @@ export function normalizeTermToDimensions(
             rateCode,
             description: priceDim.description,
             productFamily: product.productFamily ?? null,
-            attributes,
+            attributes: rowAttributes,
         });
     }
 
@@ -151,3 +151,31 @@ export interface StreamContext {
 }
 
 export type TermType = 'OnDemand' | 'Reserved';
+
+// ============================================================================
+// Spot Price History Types
+// ============================================================================
+
+/** An entry of `aws ec2 describe-spot-price-history` output */
+export interface SpotPriceHistoryEntry {
+    AvailabilityZone: string;
+    InstanceType: string;
+    ProductDescription: string;
+    SpotPrice: string;
+    Timestamp: string;
+}
+
+export interface SpotPriceHistoryFile {
+    SpotPriceHistory: SpotPriceHistoryEntry[];
+    NextToken?: string;
+}
+
+export interface SpotPrice {
+    regionCode: string;
+    availabilityZone: string;
+    instanceType: string;
+    productDescription: string;
+    operatingSystem: string;
+    spotPrice: number;
+    timestamp: string;
+}